@@ -0,0 +1,383 @@
+// Package attack demonstrates a double-spend by a miner with a majority of the
+// hash power. The attacker forks the chain just before a payment, mines in
+// private a branch where the same money goes to itself, and releases the
+// branch once it is longer. The honest nodes then drop the payment when they
+// replace their chain.
+package attack
+
+import (
+	"context"
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/logs"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// maxBlocks is the maximum number of private blocks mined by Run, so that it
+// stops if the honest nodes are faster.
+const maxBlocks = 100
+
+// State is the phase of the attack
+type State string
+
+const (
+	// Idle is before the attack starts, or after a reset
+	Idle State = "idle"
+	// Mining is while the attacker mines its private branch
+	Mining State = "mining"
+	// Released is after the branch was published
+	Released State = "released"
+)
+
+// Step is a narrated step of the attack
+type Step struct {
+	Time    time.Time
+	Message string
+}
+
+// Status is a snapshot of the attack
+type Status struct {
+	State State
+	// Target is the payment that is reverted
+	Target   *bc.Transaction
+	TargetID bc.Hash
+	// DoubleSpend is the transaction spending the same money to the attacker
+	DoubleSpend *bc.Transaction
+	// Fork is the index of the first block of the private branch
+	Fork int
+	// Confirmations is the number of public blocks from the one containing the
+	// payment
+	Confirmations int
+	// PublicBlocks and PrivateBlocks are the number of blocks after the fork
+	PublicBlocks  int
+	PrivateBlocks int
+	// PublicWork and PrivateWork are the expected hashes computed after the
+	// fork, with the proof of work.
+	PublicWork  float64
+	PrivateWork float64
+	// Preferred tells if the honest nodes would adopt the private branch
+	Preferred bool
+	Steps     []Step
+}
+
+// workCounter is implemented by the consensus engines whose fork choice
+// follows the cumulative work, ie. the proof of work.
+type workCounter interface {
+	Work(chain []*bc.Block) float64
+}
+
+// NewAttacker returns an idle attacker mining on the blockchain. The double
+// spent money is sent to me.
+func NewAttacker(blockchain *bc.Blockchain, me string) *Attacker {
+	return &Attacker{
+		blockchain: blockchain,
+		me:         me,
+		state:      Idle,
+	}
+}
+
+// Attacker mines a private branch to revert a payment. It runs beside the
+// handlers of the node, so it reads the public chain with Snapshot.
+type Attacker struct {
+	sync.Mutex
+
+	blockchain *bc.Blockchain
+	me         string
+
+	state       State
+	target      *bc.Transaction
+	targetID    bc.Hash
+	doubleSpend *bc.Transaction
+	branch      []*bc.Block
+	fork        int
+	steps       []Step
+}
+
+// Start forks the chain before the block containing the payment, and adds to
+// the private branch a transaction sending the same money to the attacker. The
+// payment must not spend signed outputs, whose signature the attacker cannot
+// forge.
+func (a *Attacker) Start(ctx context.Context, txID bc.Hash) error {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.state == Mining {
+		return xerrors.Errorf("an attack is already running")
+	}
+
+	location := a.blockchain.LocateTransaction(txID)
+	if location == nil {
+		return xerrors.Errorf("transaction %s not found", txID)
+	}
+
+	if location.Block == nil {
+		return xerrors.Errorf("transaction %s is not in a block yet", txID)
+	}
+
+	if location.Block.Index == 0 {
+		return xerrors.Errorf("the genesis block cannot be reverted")
+	}
+
+	// the signature of the inputs covers the receiver: only the owner of the
+	// spent outputs could sign a transaction sending them to the attacker
+	if len(location.Tx.Inputs) > 0 {
+		return xerrors.Errorf("transaction %s spends outputs signed by their "+
+			"owner: the attacker cannot sign a transaction sending them to "+
+			"itself, pick a payment without inputs", txID)
+	}
+
+	if location.Tx.Amount <= 0 {
+		return xerrors.Errorf("transaction %s moves no money", txID)
+	}
+
+	doubleSpend := *location.Tx
+	doubleSpend.Receiver = a.me
+	// the output is not locked to the key of the original receiver anymore
+	doubleSpend.LockScript = ""
+
+	a.state = Mining
+	a.steps = nil
+	a.target = location.Tx
+	a.targetID = txID
+	a.doubleSpend = &doubleSpend
+	a.fork = location.Block.Index
+	a.branch = append([]*bc.Block{}, a.blockchain.Snapshot().Chain[:a.fork]...)
+
+	a.narrate(ctx, "The payment of %d from %s to %s is in block %d, with %d "+
+		"confirmation(s). The attacker forks the chain at block %d and prepares a "+
+		"transaction sending the same %d to itself (%s).", location.Tx.Amount,
+		location.Tx.Sender, location.Tx.Receiver, a.fork, a.confirmations(),
+		a.fork-1, location.Tx.Amount, a.me)
+
+	a.narrate(ctx, "The attacker now mines its branch in private: the honest "+
+		"nodes do not see it and keep extending the public chain.")
+
+	return nil
+}
+
+// MineBlock mines a block on the private branch. The first one contains the
+// double spend.
+func (a *Attacker) MineBlock(ctx context.Context) error {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.mineBlock(ctx)
+}
+
+// Release publishes the private branch, if the honest nodes would adopt it
+func (a *Attacker) Release(ctx context.Context) error {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.release(ctx)
+}
+
+// Run mines private blocks until the branch is preferred, then releases it
+func (a *Attacker) Run(ctx context.Context) error {
+	a.Lock()
+	defer a.Unlock()
+
+	for i := 0; i < maxBlocks && !a.preferred(); i++ {
+		err := a.mineBlock(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.release(ctx)
+}
+
+// Reset stops the attack and forgets the private branch
+func (a *Attacker) Reset() {
+	a.Lock()
+	defer a.Unlock()
+
+	a.state = Idle
+	a.target = nil
+	a.doubleSpend = nil
+	a.branch = nil
+	a.steps = nil
+}
+
+// Status returns a snapshot of the attack
+func (a *Attacker) Status() Status {
+	a.Lock()
+	defer a.Unlock()
+
+	status := Status{
+		State:       a.state,
+		Target:      a.target,
+		TargetID:    a.targetID,
+		DoubleSpend: a.doubleSpend,
+		Fork:        a.fork,
+		Steps:       append([]Step{}, a.steps...),
+	}
+
+	if a.state == Idle {
+		return status
+	}
+
+	chain := a.blockchain.Snapshot().Chain
+
+	status.Confirmations = a.confirmations()
+	status.PublicBlocks = len(chain) - a.fork
+	status.PrivateBlocks = len(a.branch) - a.fork
+	status.Preferred = a.preferred()
+
+	counter, ok := a.blockchain.Consensus.(workCounter)
+	if ok {
+		base := counter.Work(chain[:a.fork])
+		status.PublicWork = counter.Work(chain) - base
+		status.PrivateWork = counter.Work(a.branch) - base
+	}
+
+	return status
+}
+
+func (a *Attacker) mineBlock(ctx context.Context) error {
+	if a.state != Mining {
+		return xerrors.Errorf("start an attack first")
+	}
+
+	prev := a.branch[len(a.branch)-1]
+	prevHash, err := prev.Hash()
+	if err != nil {
+		return xerrors.Errorf("failed to get hash: %v", err)
+	}
+
+	txs := make([]*bc.Transaction, 0, 2)
+	if len(a.branch) == a.fork {
+		txs = append(txs, a.doubleSpend)
+	}
+
+	// transaction fee, like the honest miners
+	txs = append(txs, bc.NewFeeTransaction(a.blockchain.Address, a.me, len(a.branch)))
+
+	block := bc.NewBlock(len(a.branch), a.blockchain.Clock.Now(), 0, prevHash, txs)
+
+	err = a.blockchain.Consensus.Seal(a.branch, block)
+	if err != nil {
+		return xerrors.Errorf("failed to seal block: %v", err)
+	}
+
+	branch := append(a.branch[:len(a.branch):len(a.branch)], block)
+
+	valid, err := a.blockchain.IsCHainValid(branch)
+	if err != nil {
+		return xerrors.Errorf("failed to validate the private branch: %v", err)
+	}
+
+	if !valid {
+		return xerrors.Errorf("private block %d is invalid: the honest nodes "+
+			"would refuse the branch", block.Index)
+	}
+
+	a.branch = branch
+
+	private := len(a.branch) - a.fork
+	public := len(a.blockchain.Snapshot().Chain) - a.fork
+
+	msg := fmt.Sprintf("Private block %d mined. The private branch has %d "+
+		"block(s) after the fork, the public chain %d.", block.Index, private, public)
+
+	if private == 1 {
+		msg += " It contains the double spend."
+	}
+
+	if a.preferred() {
+		msg += " The private branch is now the one the honest nodes would " +
+			"choose: it can be released."
+	}
+
+	a.narrate(ctx, "%s", msg)
+
+	return nil
+}
+
+func (a *Attacker) release(ctx context.Context) error {
+	if a.state != Mining {
+		return xerrors.Errorf("start an attack first")
+	}
+
+	if !a.preferred() {
+		return xerrors.Errorf("the private branch has %d block(s) after the fork, "+
+			"the public chain %d: the honest nodes would ignore it",
+			len(a.branch)-a.fork, len(a.blockchain.Snapshot().Chain)-a.fork)
+	}
+
+	confirmations := a.confirmations()
+
+	reorg, err := a.blockchain.SetChain(a.branch, false)
+	if err != nil {
+		return xerrors.Errorf("failed to release the branch: %v", err)
+	}
+
+	a.state = Released
+
+	a.narrate(ctx, "The attacker releases its branch: %d block(s) of the public "+
+		"chain are replaced, from block %d. The payment had %d confirmation(s).",
+		reorg.OldHeight-reorg.Fork, reorg.Fork, confirmations)
+
+	a.narrate(ctx, "When the honest nodes replace their chain, they adopt the "+
+		"longer branch and the payment to %s disappears: the %d went back to "+
+		"the attacker.", a.target.Receiver, a.target.Amount)
+
+	return nil
+}
+
+// preferred tells if the honest nodes would replace the public chain by the
+// private branch.
+func (a *Attacker) preferred() bool {
+	return a.blockchain.Consensus.ForkChoice(a.blockchain.Snapshot().Chain, a.branch)
+}
+
+// confirmations returns the number of public blocks from the one containing
+// the payment, or 0 if it is not in the public chain anymore.
+func (a *Attacker) confirmations() int {
+	location := a.blockchain.LocateTransaction(a.targetID)
+	if location == nil || location.Block == nil {
+		return 0
+	}
+
+	return len(a.blockchain.Snapshot().Chain) - location.Block.Index
+}
+
+func (a *Attacker) narrate(ctx context.Context, format string, args ...interface{}) {
+	step := Step{
+		Time:    a.blockchain.Clock.Now(),
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	a.steps = append(a.steps, step)
+
+	logs.FromContext(ctx).Info("attack", "step", step.Message)
+	a.blockchain.Events.Publish(bc.EventAttack, step)
+}
+
+// CatchUpProbability returns the probability that an attacker with a share q
+// of the hash power ever catches up with the honest chain when it is z blocks
+// behind, as computed in the section 11 of the Bitcoin paper.
+func CatchUpProbability(q float64, z int) float64 {
+	p := 1 - q
+	if q >= p {
+		return 1
+	}
+
+	lambda := float64(z) * q / p
+	sum := 1.0
+
+	for k := 0; k <= z; k++ {
+		poisson := math.Exp(-lambda)
+		for i := 1; i <= k; i++ {
+			poisson *= lambda / float64(i)
+		}
+
+		sum -= poisson * (1 - math.Pow(q/p, float64(z-k)))
+	}
+
+	return sum
+}
@@ -0,0 +1,113 @@
+package attack
+
+import (
+	"context"
+	bc "dummy-blockchain/blockchain"
+	"strings"
+	"testing"
+)
+
+func newTestBlockchain(t *testing.T) *bc.Blockchain {
+	t.Helper()
+
+	genesis := bc.DefaultGenesis()
+	genesis.Alloc = map[string]int{"alice": 100}
+
+	return bc.NewBlockchain("node", genesis, bc.NewProofOfWork("0"), nil, nil)
+}
+
+// pay adds the transaction and mines it, returning its ID
+func pay(t *testing.T, blockchain *bc.Blockchain, tx *bc.Transaction) bc.Hash {
+	t.Helper()
+
+	_, err := blockchain.AddTransaction(tx)
+	if err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+
+	_, err = blockchain.MineBlock(context.Background(), "owner")
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+
+	id, err := tx.ID()
+	if err != nil {
+		t.Fatalf("failed to get id: %v", err)
+	}
+
+	return id
+}
+
+func TestDoubleSpend(t *testing.T) {
+	blockchain := newTestBlockchain(t)
+	id := pay(t, blockchain, bc.NewTransaction("alice", "merchant", 40))
+
+	attacker := NewAttacker(blockchain, "mallory")
+
+	err := attacker.Start(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	err = attacker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+
+	if blockchain.LocateTransaction(id) != nil {
+		t.Fatal("the payment is still in the chain")
+	}
+
+	doubleSpendID, err := attacker.Status().DoubleSpend.ID()
+	if err != nil {
+		t.Fatalf("failed to get id: %v", err)
+	}
+
+	if blockchain.LocateTransaction(doubleSpendID) == nil {
+		t.Fatal("the double spend is not in the chain")
+	}
+
+	if balances := bc.Balances(blockchain.Chain); balances["merchant"] != 0 {
+		t.Fatalf("balances are %v, expected the merchant to have nothing", balances)
+	}
+}
+
+func TestDoubleSpendRefusesSignedPayment(t *testing.T) {
+	blockchain := newTestBlockchain(t)
+
+	pub, priv, err := bc.NewKeyPair(bc.NewSeededEntropy(1))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	hash, err := bc.PubKeyHash(pub)
+	if err != nil {
+		t.Fatalf("failed to hash key: %v", err)
+	}
+
+	locked := bc.NewTransaction("alice", pub, 40)
+	locked.LockScript = bc.NewP2PKHScript(hash)
+	lockedID := pay(t, blockchain, locked)
+
+	payment := bc.NewTransaction(pub, "merchant", 40)
+	payment.Inputs = []*bc.Input{{PrevTx: lockedID}}
+
+	sig, err := payment.Sign(priv, blockchain.Genesis.ChainID)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	payment.Inputs[0].UnlockScript = bc.Script("<" + sig + "> <" + pub + ">")
+	id := pay(t, blockchain, payment)
+
+	attacker := NewAttacker(blockchain, "mallory")
+
+	err = attacker.Start(context.Background(), id)
+	if err == nil || !strings.Contains(err.Error(), "cannot sign") {
+		t.Fatalf("attack started, or wrong error: %v", err)
+	}
+
+	if attacker.Status().State != Idle {
+		t.Fatalf("attack is %s instead of idle", attacker.Status().State)
+	}
+}
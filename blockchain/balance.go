@@ -0,0 +1,30 @@
+package blockchain
+
+// Balances returns the balance of every account of the chain. Transactions
+// debit their sender and credit their receiver.
+func Balances(blocks []*Block) map[string]int {
+	balances := make(map[string]int)
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			balances[tx.Sender] -= tx.Amount
+			balances[tx.Receiver] += tx.Amount
+		}
+	}
+
+	return balances
+}
+
+// GetBalance returns the balance of an account on the chain
+func (b *Blockchain) GetBalance(address string) int {
+	return Balances(b.Snapshot().Chain)[address]
+}
+
+// pendingBalance returns the balance of an account once the pending
+// transactions are included
+func (b *Blockchain) pendingBalance(address string) int {
+	pending := &Block{Transactions: b.Transactions}
+	chain := append(b.Chain[:len(b.Chain):len(b.Chain)], pending)
+
+	return Balances(chain)[address]
+}
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -42,37 +43,105 @@ func (h *Hash) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseHash parses the hex representation of a hash
+func ParseHash(hashStr string) (Hash, error) {
+	var h Hash
+
+	buf, err := hex.DecodeString(hashStr)
+	if err != nil {
+		return h, xerrors.Errorf("failed to decode Hash hex: %v", err)
+	}
+
+	if len(buf) != 32 {
+		return h, xerrors.Errorf("len of hash should be == 32: %d", len(buf))
+	}
+
+	copy(h[:], buf)
+
+	return h, nil
+}
+
 // String returns a string representation of a hash
 func (h Hash) String() string {
 	return hex.EncodeToString(h[:])
 }
 
-// NewBlock creates a new block
-func NewBlock(index int, proof int, prevHash Hash, txs []*Transaction) *Block {
+// NewBlock creates a new block with the given timestamp
+func NewBlock(index int, timestamp time.Time, proof int, prevHash Hash,
+	txs []*Transaction) *Block {
+
 	return &Block{
 		Index:        index,
-		Timestamp:    time.Now().UnixNano(),
+		Timestamp:    timestamp.UnixNano(),
 		Proof:        proof,
 		PrevHash:     prevHash,
 		Transactions: txs,
 	}
 }
 
-// Block represents a block on the chain
+// Block represents a block on the chain. Signer and Signature are only used
+// by the consensus engines where blocks are signed instead of mined.
 type Block struct {
 	Index        int
 	Timestamp    int64
 	Proof        int
 	PrevHash     Hash
 	Transactions []*Transaction
+	Signer       string
+	Signature    string
 }
 
-// Hash outputs the hash of the JSON representation of the block
-func (b Block) Hash() ([32]byte, error) {
-	encodedBlock, err := json.Marshal(b)
+// Hash outputs the hash of the binary encoding of the block
+func (b Block) Hash() (Hash, error) {
+	encodedBlock, err := b.MarshalBinary()
 	if err != nil {
-		return [32]byte{}, xerrors.Errorf("failed to marshal to json: %v", err)
+		return Hash{}, xerrors.Errorf("failed to encode block: %v", err)
 	}
 
 	return sha256.Sum256(encodedBlock), nil
 }
+
+// SealHash returns the hash of the block without its signature, which is what
+// the signer signs.
+func (b Block) SealHash() (Hash, error) {
+	b.Signature = ""
+	return b.Hash()
+}
+
+// Sign sets the signer and the signature of the block
+func (b *Block) Sign(privKey ed25519.PrivateKey) error {
+	b.Signer = PublicKeyOf(privKey)
+
+	sealHash, err := b.SealHash()
+	if err != nil {
+		return xerrors.Errorf("failed to get seal hash: %v", err)
+	}
+
+	b.Signature = hex.EncodeToString(ed25519.Sign(privKey, sealHash[:]))
+
+	return nil
+}
+
+// VerifySignature checks that the block is signed by its signer
+func (b Block) VerifySignature() error {
+	pubKey, err := hex.DecodeString(b.Signer)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return xerrors.Errorf("invalid signer '%s'", b.Signer)
+	}
+
+	sig, err := hex.DecodeString(b.Signature)
+	if err != nil {
+		return xerrors.Errorf("invalid signature: %v", err)
+	}
+
+	sealHash, err := b.SealHash()
+	if err != nil {
+		return xerrors.Errorf("failed to get seal hash: %v", err)
+	}
+
+	if !ed25519.Verify(pubKey, sealHash[:], sig) {
+		return xerrors.Errorf("wrong signature from %s", b.Signer)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock gives the time of the node, ie. the timestamp of the blocks
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the clock of the machine
+//
+// - implements Clock
+type SystemClock struct{}
+
+// Now implements Clock
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewSimulatedClock returns a clock starting at start, which moves forward by
+// step each time it is read.
+func NewSimulatedClock(start time.Time, step time.Duration) *SimulatedClock {
+	return &SimulatedClock{
+		now:  start,
+		step: step,
+	}
+}
+
+// SimulatedClock is a clock independent of the machine, so that the same
+// actions always give the same timestamps.
+//
+// - implements Clock
+type SimulatedClock struct {
+	sync.Mutex
+
+	now  time.Time
+	step time.Duration
+}
+
+// Now implements Clock. It returns the current time and moves the clock
+// forward by its step.
+func (c *SimulatedClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now
+	c.now = c.now.Add(c.step)
+
+	return now
+}
+
+// Advance moves the clock forward, ie. to reach the next slot of the proof of
+// stake.
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// NewSeededEntropy returns a source of random bytes which always gives the
+// same bytes for the same seed. It must not be used for real keys.
+func NewSeededEntropy(seed int64) io.Reader {
+	return &seededEntropy{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// seededEntropy is a math/rand source safe for concurrent use
+type seededEntropy struct {
+	sync.Mutex
+
+	rand *rand.Rand
+}
+
+// Read implements io.Reader
+func (e *seededEntropy) Read(p []byte) (int, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.rand.Read(p)
+}
@@ -0,0 +1,36 @@
+package blockchain
+
+// Consensus is the engine that decides who can create blocks and which chain
+// is the right one. A node uses the same engine to seal the blocks it creates
+// and to verify the blocks it receives.
+type Consensus interface {
+	// Name returns the name of the engine, as given to the -consensus flag.
+	Name() string
+
+	// Seal fills the consensus fields of a new block that will be appended to
+	// the chain, ie. its proof.
+	Seal(chain []*Block, block *Block) error
+
+	// Verify checks the consensus fields of a block appended to the chain.
+	Verify(chain []*Block, block *Block) error
+
+	// ForkChoice returns true if the candidate chain should replace the
+	// current one. Both chains are valid.
+	ForkChoice(current, candidate []*Block) bool
+}
+
+// EvidenceCollector is implemented by the consensus engines that can find
+// evidence of misbehavior when comparing our chain with a peer's chain.
+type EvidenceCollector interface {
+	CollectEvidence(current, candidate []*Block) []*SlashingEvidence
+}
+
+// TransactionChecker is implemented by the consensus engines whose
+// transactions depend on the state of the chain, ie. the votes. The pending
+// transactions that do not pass the check are refused, or left out of the
+// blocks if the chain changed since they were added.
+type TransactionChecker interface {
+	// CheckTransaction returns an error if the transaction cannot follow the
+	// previous transactions in the next block of the chain.
+	CheckTransaction(chain []*Block, previous []*Transaction, tx *Transaction) error
+}
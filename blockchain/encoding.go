@@ -0,0 +1,567 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"golang.org/x/xerrors"
+)
+
+// EncodingVersion is the version of the binary encoding of the blocks and the
+// transactions. It is the first byte of each encoding, so it is part of the
+// hashes: a new version gives new hashes. The decoder only accepts this
+// version, the encodings of another version are refused: reading them would
+// need the decoder of their version.
+//
+// The encoding of a value is unique, and made of:
+//
+//   - integers: signed varints, as in encoding/binary
+//   - lengths and counts: unsigned varints
+//   - strings: their length, then their UTF-8 bytes
+//   - hashes: their 32 bytes
+//   - booleans and optional values: a byte, 0 or 1, then the value if 1
+//   - nested blocks and transactions: the length of their encoding, then
+//     their encoding, with its own version
+//
+// Block, version 1: version, Index, Timestamp, Proof, PrevHash, the count of
+// Transactions then each transaction, Signer, Signature.
+//
+// Transaction, version 1: version, Sender, Receiver, Amount, LockScript, the
+// count of Inputs then for each its PrevTx and UnlockScript, LockTime, the
+// optional Vote, the optional Evidence.
+//
+// Vote, version 1: version, Validator, Candidate, Add, Epoch, Signature.
+//
+// SlashingEvidence: the optional BlockA, the optional BlockB.
+const EncodingVersion = 1
+
+// maxEncodingDepth is the maximum nesting of the encoded values, ie. a block
+// in the evidence of a transaction of a block.
+const maxEncodingDepth = 8
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the
+// canonical encoding of the block.
+func (b Block) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.block(&b)
+
+	return e.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It only accepts the
+// canonical encoding of a block.
+func (b *Block) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+
+	decoded, err := d.block(0)
+	if err != nil {
+		return xerrors.Errorf("failed to decode block: %v", err)
+	}
+
+	err = d.finish()
+	if err != nil {
+		return xerrors.Errorf("failed to decode block: %v", err)
+	}
+
+	// each value has a single encoding, so the hash of a block does not
+	// depend on how it was received.
+	encoded, _ := decoded.MarshalBinary()
+	if !bytes.Equal(encoded, data) {
+		return xerrors.Errorf("failed to decode block: not canonical")
+	}
+
+	*b = *decoded
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the
+// canonical encoding of the transaction.
+func (t Transaction) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.transaction(&t)
+
+	return e.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It only accepts the
+// canonical encoding of a transaction.
+func (t *Transaction) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+
+	decoded, err := d.transaction(0)
+	if err != nil {
+		return xerrors.Errorf("failed to decode transaction: %v", err)
+	}
+
+	err = d.finish()
+	if err != nil {
+		return xerrors.Errorf("failed to decode transaction: %v", err)
+	}
+
+	encoded, _ := decoded.MarshalBinary()
+	if !bytes.Equal(encoded, data) {
+		return xerrors.Errorf("failed to decode transaction: not canonical")
+	}
+
+	*t = *decoded
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the
+// canonical encoding of the vote.
+func (v Vote) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.vote(&v)
+
+	return e.Bytes(), nil
+}
+
+// encoder writes the canonical encoding
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) int(i int64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutVarint(buf[:], i)])
+}
+
+func (e *encoder) uint(u uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutUvarint(buf[:], u)])
+}
+
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.WriteByte(1)
+	} else {
+		e.WriteByte(0)
+	}
+}
+
+// nested writes a value with its length
+func (e *encoder) nested(write func(*encoder)) {
+	inner := &encoder{}
+	write(inner)
+
+	e.uint(uint64(inner.Len()))
+	e.Write(inner.Bytes())
+}
+
+func (e *encoder) block(b *Block) {
+	e.WriteByte(EncodingVersion)
+	e.int(int64(b.Index))
+	e.int(b.Timestamp)
+	e.int(int64(b.Proof))
+	e.Write(b.PrevHash[:])
+
+	e.uint(uint64(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		e.nested(func(inner *encoder) {
+			inner.transaction(tx)
+		})
+	}
+
+	e.string(b.Signer)
+	e.string(b.Signature)
+}
+
+func (e *encoder) transaction(t *Transaction) {
+	e.WriteByte(EncodingVersion)
+	e.string(t.Sender)
+	e.string(t.Receiver)
+	e.int(int64(t.Amount))
+	e.string(string(t.LockScript))
+
+	e.uint(uint64(len(t.Inputs)))
+	for _, input := range t.Inputs {
+		e.Write(input.PrevTx[:])
+		e.string(string(input.UnlockScript))
+	}
+
+	e.int(t.LockTime)
+
+	e.bool(t.Vote != nil)
+	if t.Vote != nil {
+		e.nested(func(inner *encoder) {
+			inner.vote(t.Vote)
+		})
+	}
+
+	e.bool(t.Evidence != nil)
+	if t.Evidence != nil {
+		for _, block := range []*Block{t.Evidence.BlockA, t.Evidence.BlockB} {
+			e.bool(block != nil)
+			if block != nil {
+				e.nested(func(inner *encoder) {
+					inner.block(block)
+				})
+			}
+		}
+	}
+}
+
+func (e *encoder) vote(v *Vote) {
+	e.WriteByte(EncodingVersion)
+	e.string(v.Validator)
+	e.string(v.Candidate)
+	e.bool(v.Add)
+	e.int(int64(v.Epoch))
+	e.string(v.Signature)
+}
+
+// decoder reads the canonical encoding. Every length is checked against the
+// remaining bytes, so that invalid data fails instead of allocating too much.
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) finish() error {
+	if len(d.data) > 0 {
+		return xerrors.Errorf("%d trailing bytes", len(d.data))
+	}
+
+	return nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	if len(d.data) == 0 {
+		return 0, xerrors.Errorf("unexpected end of data")
+	}
+
+	b := d.data[0]
+	d.data = d.data[1:]
+
+	return b, nil
+}
+
+func (d *decoder) version() error {
+	version, err := d.byte()
+	if err != nil {
+		return err
+	}
+
+	if version != EncodingVersion {
+		return xerrors.Errorf("unsupported version %d", version)
+	}
+
+	return nil
+}
+
+func (d *decoder) int() (int64, error) {
+	i, n := binary.Varint(d.data)
+	if n <= 0 {
+		return 0, xerrors.Errorf("invalid varint")
+	}
+
+	d.data = d.data[n:]
+
+	return i, nil
+}
+
+// length reads a length or a count, which cannot be more than the remaining
+// bytes.
+func (d *decoder) length() (int, error) {
+	u, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, xerrors.Errorf("invalid uvarint")
+	}
+
+	d.data = d.data[n:]
+
+	if u > uint64(len(d.data)) {
+		return 0, xerrors.Errorf("length %d is more than the %d remaining bytes",
+			u, len(d.data))
+	}
+
+	return int(u), nil
+}
+
+func (d *decoder) bytes(n int) []byte {
+	buf := d.data[:n]
+	d.data = d.data[n:]
+
+	return buf
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.length()
+	if err != nil {
+		return "", err
+	}
+
+	return string(d.bytes(n)), nil
+}
+
+func (d *decoder) hash() (Hash, error) {
+	var h Hash
+
+	if len(d.data) < len(h) {
+		return h, xerrors.Errorf("unexpected end of data")
+	}
+
+	copy(h[:], d.bytes(len(h)))
+
+	return h, nil
+}
+
+func (d *decoder) bool() (bool, error) {
+	b, err := d.byte()
+	if err != nil {
+		return false, err
+	}
+
+	switch b {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, xerrors.Errorf("invalid boolean %d", b)
+	}
+}
+
+// nested returns a decoder of a value written with its length
+func (d *decoder) nested() (*decoder, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+
+	return &decoder{data: d.bytes(n)}, nil
+}
+
+func (d *decoder) block(depth int) (*Block, error) {
+	if depth > maxEncodingDepth {
+		return nil, xerrors.Errorf("too deeply nested")
+	}
+
+	err := d.version()
+	if err != nil {
+		return nil, err
+	}
+
+	var b Block
+	var i int64
+
+	i, err = d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid index: %v", err)
+	}
+	b.Index = int(i)
+
+	b.Timestamp, err = d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid timestamp: %v", err)
+	}
+
+	i, err = d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid proof: %v", err)
+	}
+	b.Proof = int(i)
+
+	b.PrevHash, err = d.hash()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid previous hash: %v", err)
+	}
+
+	count, err := d.length()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid number of transactions: %v", err)
+	}
+
+	b.Transactions = make([]*Transaction, count)
+	for j := range b.Transactions {
+		inner, err := d.nested()
+		if err != nil {
+			return nil, xerrors.Errorf("invalid transaction %d: %v", j, err)
+		}
+
+		b.Transactions[j], err = inner.transaction(depth + 1)
+		if err == nil {
+			err = inner.finish()
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("invalid transaction %d: %v", j, err)
+		}
+	}
+
+	b.Signer, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid signer: %v", err)
+	}
+
+	b.Signature, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid signature: %v", err)
+	}
+
+	return &b, nil
+}
+
+func (d *decoder) transaction(depth int) (*Transaction, error) {
+	if depth > maxEncodingDepth {
+		return nil, xerrors.Errorf("too deeply nested")
+	}
+
+	err := d.version()
+	if err != nil {
+		return nil, err
+	}
+
+	var t Transaction
+
+	t.Sender, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid sender: %v", err)
+	}
+
+	t.Receiver, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid receiver: %v", err)
+	}
+
+	amount, err := d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid amount: %v", err)
+	}
+	t.Amount = int(amount)
+
+	lockScript, err := d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid lock script: %v", err)
+	}
+	t.LockScript = Script(lockScript)
+
+	count, err := d.length()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid number of inputs: %v", err)
+	}
+
+	if count > 0 {
+		t.Inputs = make([]*Input, count)
+	}
+
+	for j := range t.Inputs {
+		var input Input
+
+		input.PrevTx, err = d.hash()
+		if err != nil {
+			return nil, xerrors.Errorf("invalid input %d: %v", j, err)
+		}
+
+		unlockScript, err := d.string()
+		if err != nil {
+			return nil, xerrors.Errorf("invalid input %d: %v", j, err)
+		}
+		input.UnlockScript = Script(unlockScript)
+
+		t.Inputs[j] = &input
+	}
+
+	t.LockTime, err = d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid lock time: %v", err)
+	}
+
+	hasVote, err := d.bool()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid vote: %v", err)
+	}
+
+	if hasVote {
+		inner, err := d.nested()
+		if err != nil {
+			return nil, xerrors.Errorf("invalid vote: %v", err)
+		}
+
+		t.Vote, err = inner.vote()
+		if err == nil {
+			err = inner.finish()
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("invalid vote: %v", err)
+		}
+	}
+
+	hasEvidence, err := d.bool()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid evidence: %v", err)
+	}
+
+	if hasEvidence {
+		t.Evidence = &SlashingEvidence{}
+
+		for _, dst := range []**Block{&t.Evidence.BlockA, &t.Evidence.BlockB} {
+			hasBlock, err := d.bool()
+			if err != nil {
+				return nil, xerrors.Errorf("invalid evidence: %v", err)
+			}
+
+			if !hasBlock {
+				continue
+			}
+
+			inner, err := d.nested()
+			if err != nil {
+				return nil, xerrors.Errorf("invalid evidence: %v", err)
+			}
+
+			*dst, err = inner.block(depth + 1)
+			if err == nil {
+				err = inner.finish()
+			}
+			if err != nil {
+				return nil, xerrors.Errorf("invalid evidence: %v", err)
+			}
+		}
+	}
+
+	return &t, nil
+}
+
+func (d *decoder) vote() (*Vote, error) {
+	err := d.version()
+	if err != nil {
+		return nil, err
+	}
+
+	var v Vote
+
+	v.Validator, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid validator: %v", err)
+	}
+
+	v.Candidate, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid candidate: %v", err)
+	}
+
+	v.Add, err = d.bool()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid add: %v", err)
+	}
+
+	epoch, err := d.int()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid epoch: %v", err)
+	}
+
+	v.Epoch = int(epoch)
+
+	v.Signature, err = d.string()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid signature: %v", err)
+	}
+
+	return &v, nil
+}
@@ -0,0 +1,255 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// the golden vectors are the version 1 encodings of fixed values. They must
+// never change: a change of the encoding changes the hashes of every chain,
+// and needs a new EncodingVersion.
+const (
+	goldenVoteHex         = "010261610262620106026363"
+	goldenUnsignedVoteHex = "01026161026262010600"
+	// goldenVoteHash is the hash signed on the "dummy" network
+	goldenVoteHash = "1a8beb9ed91c876d48e70d81e0e52d255df03766cabe9e3275257fcd459e9161"
+
+	goldenTransactionHex = "0105616c69636503626f62140344555001010203000000000000" +
+		"00000000000000000000000000000000000000000000000373696701010c01026161" +
+		"0262620106026363" + "00"
+	goldenTransactionID = "8535ffec2c77e5a048b7644414ebfa5e330aaf5850b9b3b69fd331c1fdc865f2"
+
+	goldenBlockHex = "01048084dfe00bd804" +
+		"ff000000000000000000000000000000" +
+		"00000000000000000000000000000000" +
+		"02" + "45" + goldenTransactionHex +
+		"12" + "01046e6f6465056f776e6572020000040000" + "067369676e6572" + "03736967"
+	goldenBlockHash = "b232e11608413212e2cd8f448c59e83eadc42a862c264ca1324cbafddaf4e8b8"
+)
+
+func goldenVote() *Vote {
+	return &Vote{
+		Validator: "aa",
+		Candidate: "bb",
+		Add:       true,
+		Epoch:     3,
+		Signature: "cc",
+	}
+}
+
+func goldenTransaction() *Transaction {
+	return &Transaction{
+		Sender:     "alice",
+		Receiver:   "bob",
+		Amount:     10,
+		LockScript: Script("DUP"),
+		Inputs: []*Input{
+			{PrevTx: Hash{1, 2, 3}, UnlockScript: Script("sig")},
+		},
+		LockTime: -1,
+		Vote:     goldenVote(),
+	}
+}
+
+func goldenBlock() *Block {
+	return &Block{
+		Index:     2,
+		Timestamp: 1577836800,
+		Proof:     300,
+		PrevHash:  Hash{0xff},
+		Transactions: []*Transaction{
+			goldenTransaction(),
+			NewFeeTransaction("node", "owner", 2),
+		},
+		Signer:    "signer",
+		Signature: "sig",
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid golden hex: %v", err)
+	}
+
+	return data
+}
+
+// checkGolden checks an encoding against its golden hex, and the golden hex
+// against its fixed hash
+func checkGolden(t *testing.T, encoded []byte, goldenHex string, goldenHash string) {
+	t.Helper()
+
+	golden := decodeHex(t, goldenHex)
+
+	if !bytes.Equal(encoded, golden) {
+		t.Fatalf("encoding changed:\n got  %x\n want %s", encoded, goldenHex)
+	}
+
+	h := sha256.Sum256(golden)
+	if hex.EncodeToString(h[:]) != goldenHash {
+		t.Fatalf("hash of the golden encoding is %x instead of %s", h, goldenHash)
+	}
+}
+
+func TestVoteGoldenEncoding(t *testing.T) {
+	encoded, err := goldenVote().MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode vote: %v", err)
+	}
+
+	if hex.EncodeToString(encoded) != goldenVoteHex {
+		t.Fatalf("encoding changed:\n got  %x\n want %s", encoded, goldenVoteHex)
+	}
+
+	// the chain ID, as a string, then the vote without its signature
+	unsigned := goldenVote()
+	unsigned.Signature = ""
+
+	encoded, err = unsigned.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode vote: %v", err)
+	}
+
+	checkGolden(t, append([]byte("\x05dummy"), encoded...),
+		"0564756d6d79"+goldenUnsignedVoteHex, goldenVoteHash)
+
+	h, err := goldenVote().Hash("dummy")
+	if err != nil {
+		t.Fatalf("failed to hash vote: %v", err)
+	}
+
+	if hex.EncodeToString(h[:]) != goldenVoteHash {
+		t.Fatalf("vote hash is %x instead of %s", h, goldenVoteHash)
+	}
+}
+
+func TestTransactionGoldenEncoding(t *testing.T) {
+	encoded, err := goldenTransaction().MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+
+	checkGolden(t, encoded, goldenTransactionHex, goldenTransactionID)
+
+	id, err := goldenTransaction().ID()
+	if err != nil {
+		t.Fatalf("failed to get ID: %v", err)
+	}
+
+	if id.String() != goldenTransactionID {
+		t.Fatalf("transaction ID is %s instead of %s", id, goldenTransactionID)
+	}
+
+	var decoded Transaction
+	err = decoded.UnmarshalBinary(decodeHex(t, goldenTransactionHex))
+	if err != nil {
+		t.Fatalf("failed to decode golden transaction: %v", err)
+	}
+
+	id, err = decoded.ID()
+	if err != nil {
+		t.Fatalf("failed to get ID: %v", err)
+	}
+
+	if id.String() != goldenTransactionID {
+		t.Fatalf("decoded transaction ID is %s instead of %s", id,
+			goldenTransactionID)
+	}
+}
+
+func TestBlockGoldenEncoding(t *testing.T) {
+	encoded, err := goldenBlock().MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode block: %v", err)
+	}
+
+	checkGolden(t, encoded, goldenBlockHex, goldenBlockHash)
+
+	h, err := goldenBlock().Hash()
+	if err != nil {
+		t.Fatalf("failed to hash block: %v", err)
+	}
+
+	if h.String() != goldenBlockHash {
+		t.Fatalf("block hash is %s instead of %s", h, goldenBlockHash)
+	}
+
+	var decoded Block
+	err = decoded.UnmarshalBinary(decodeHex(t, goldenBlockHex))
+	if err != nil {
+		t.Fatalf("failed to decode golden block: %v", err)
+	}
+
+	h, err = decoded.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash block: %v", err)
+	}
+
+	if h.String() != goldenBlockHash {
+		t.Fatalf("decoded block hash is %s instead of %s", h, goldenBlockHash)
+	}
+}
+
+func TestUnmarshalRejectsOtherVersions(t *testing.T) {
+	data := decodeHex(t, goldenBlockHex)
+	data[0] = EncodingVersion + 1
+
+	var block Block
+	err := block.UnmarshalBinary(data)
+	if err == nil {
+		t.Fatal("block of another encoding version decoded")
+	}
+}
+
+func FuzzBlockUnmarshalBinary(f *testing.F) {
+	golden, err := hex.DecodeString(goldenBlockHex)
+	if err != nil {
+		f.Fatalf("invalid golden hex: %v", err)
+	}
+
+	genesis, err := DefaultGenesis().Block().MarshalBinary()
+	if err != nil {
+		f.Fatalf("failed to encode genesis: %v", err)
+	}
+
+	f.Add(golden)
+	f.Add(genesis)
+	f.Add([]byte{})
+	f.Add([]byte{EncodingVersion})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var block Block
+		err := block.UnmarshalBinary(data)
+		if err != nil {
+			return
+		}
+
+		// only the canonical encoding is accepted, so it is given back
+		encoded, err := block.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to encode decoded block: %v", err)
+		}
+
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("decoded a non canonical encoding:\n got  %x\n want %x",
+				encoded, data)
+		}
+
+		var again Block
+		err = again.UnmarshalBinary(encoded)
+		if err != nil {
+			t.Fatalf("failed to decode the encoding of a decoded block: %v", err)
+		}
+
+		h1, _ := block.Hash()
+		h2, _ := again.Hash()
+		if h1 != h2 {
+			t.Fatalf("hash changed after a round trip: %s then %s", h1, h2)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package blockchain
+
+import "sync"
+
+// EventType is the kind of an event published by the blockchain
+type EventType string
+
+const (
+	// EventBlockAdded is published when a block is appended to the chain. Its
+	// data is the *Block.
+	EventBlockAdded EventType = "block-added"
+	// EventTxAdded is published when a transaction is added to the pending
+	// ones. Its data is the *Transaction.
+	EventTxAdded EventType = "tx-added"
+	// EventReorg is published when the chain is replaced by the one of
+	// another node, or by a branch mined in private. Its data is a Reorg.
+	EventReorg EventType = "reorg"
+	// EventPeerChanged is published when a node is added or rejected. Its
+	// data is a copy of the list of nodes.
+	EventPeerChanged EventType = "peer-changed"
+	// EventAttack is published at each step of the attack demonstration. Its
+	// data is the narrated step.
+	EventAttack EventType = "attack"
+)
+
+// eventBufferSize is the number of events a subscriber can lag behind before
+// it misses some.
+const eventBufferSize = 16
+
+// Event is something that happened on the node
+type Event struct {
+	Type EventType
+	Data interface{}
+}
+
+// Reorg describes a replacement of the chain
+type Reorg struct {
+	// Fork is the index of the first block that differs
+	Fork      int
+	OldHeight int
+	NewHeight int
+	Head      *Block
+}
+
+// NewEventBus returns a new event bus without subscriber
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// EventBus dispatches the events of a blockchain to its subscribers. A slow
+// subscriber misses events instead of blocking the blockchain. The handlers
+// are called synchronously and miss no event.
+type EventBus struct {
+	sync.Mutex
+	subscribers map[chan Event]struct{}
+	handlers    []func(Event)
+}
+
+// Subscribe returns a channel receiving the next events, and a function to
+// call to stop receiving them.
+func (e *EventBus) Subscribe() (<-chan Event, func()) {
+	e.Lock()
+	defer e.Unlock()
+
+	events := make(chan Event, eventBufferSize)
+	e.subscribers[events] = struct{}{}
+
+	unsubscribe := func() {
+		e.Lock()
+		defer e.Unlock()
+
+		_, found := e.subscribers[events]
+		if found {
+			delete(e.subscribers, events)
+			close(events)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// Handle calls the handler with every event, while it is published. It is
+// lossless, unlike Subscribe, but the handler blocks the blockchain: it must
+// be fast, ie. increment a counter, and must not publish.
+func (e *EventBus) Handle(handler func(Event)) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.handlers = append(e.handlers, handler)
+}
+
+// Publish calls the handlers and sends the event to every subscriber
+func (e *EventBus) Publish(eventType EventType, data interface{}) {
+	e.Lock()
+	defer e.Unlock()
+
+	event := Event{Type: eventType, Data: data}
+
+	for _, handler := range e.handlers {
+		handler(event)
+	}
+
+	for events := range e.subscribers {
+		select {
+		case events <- event:
+		default:
+			// the subscriber is too slow, drop the event
+		}
+	}
+}
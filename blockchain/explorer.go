@@ -0,0 +1,186 @@
+package blockchain
+
+import "golang.org/x/xerrors"
+
+// TxLocation tells where a transaction is stored. Block is nil for a pending
+// transaction. Index is the position of the transaction in its block, or in
+// the pending transactions.
+type TxLocation struct {
+	Tx    *Transaction
+	ID    Hash
+	Block *Block
+	Index int
+}
+
+// TxCursor is the position of a transaction in the chain, to start a page of
+// transactions after it. The pending transactions are in the block after the
+// last one. Identical transactions have the same ID, so the ID only checks
+// that the transaction is still at this position.
+type TxCursor struct {
+	Block int
+	Index int
+	ID    Hash
+}
+
+// GetBlock returns the block at the given index, or nil if there is none.
+func (b *Blockchain) GetBlock(index int) *Block {
+	chain := b.Snapshot().Chain
+	if index < 0 || index >= len(chain) {
+		return nil
+	}
+
+	return chain[index]
+}
+
+// FindBlock returns the block with the given hash, or nil if not found.
+func (b *Blockchain) FindBlock(hash Hash) *Block {
+	for _, block := range b.Snapshot().Chain {
+		blockHash, err := block.Hash()
+		if err == nil && blockHash == hash {
+			return block
+		}
+	}
+
+	return nil
+}
+
+// LocateTransaction looks for a transaction in the chain and in the pending
+// transactions. Returns nil if not found.
+func (b *Blockchain) LocateTransaction(id Hash) *TxLocation {
+	for _, loc := range b.transactionLocations() {
+		if loc.ID == id {
+			return loc
+		}
+	}
+
+	return nil
+}
+
+// AddressHistory returns the transactions sent or received by an address,
+// oldest first, followed by the pending ones.
+func (b *Blockchain) AddressHistory(address string) []*TxLocation {
+	history := make([]*TxLocation, 0)
+
+	for _, loc := range b.transactionLocations() {
+		if loc.Tx.Sender == address || loc.Tx.Receiver == address {
+			history = append(history, loc)
+		}
+	}
+
+	return history
+}
+
+// transactionLocations returns all the transactions of the chain followed by
+// the pending ones.
+func (b *Blockchain) transactionLocations() []*TxLocation {
+	locations := make([]*TxLocation, 0)
+
+	add := func(tx *Transaction, block *Block, index int) {
+		id, err := tx.ID()
+		if err != nil {
+			return
+		}
+
+		locations = append(locations, &TxLocation{
+			Tx:    tx,
+			ID:    id,
+			Block: block,
+			Index: index,
+		})
+	}
+
+	snapshot := b.Snapshot()
+
+	for _, block := range snapshot.Chain {
+		for i, tx := range block.Transactions {
+			add(tx, block, i)
+		}
+	}
+
+	for i, tx := range snapshot.Transactions {
+		add(tx, nil, i)
+	}
+
+	return locations
+}
+
+// TransactionFilter selects transactions. Empty fields match everything.
+type TransactionFilter struct {
+	Sender    string
+	Receiver  string
+	MinAmount *int
+	MaxAmount *int
+}
+
+// Match tells if the transaction is selected by the filter
+func (f TransactionFilter) Match(tx *Transaction) bool {
+	switch {
+	case f.Sender != "" && tx.Sender != f.Sender:
+		return false
+	case f.Receiver != "" && tx.Receiver != f.Receiver:
+		return false
+	case f.MinAmount != nil && tx.Amount < *f.MinAmount:
+		return false
+	case f.MaxAmount != nil && tx.Amount > *f.MaxAmount:
+		return false
+	default:
+		return true
+	}
+}
+
+// CursorOf returns the cursor to start after a transaction
+func (b *Blockchain) CursorOf(loc *TxLocation) TxCursor {
+	cursor := TxCursor{
+		Block: len(b.Chain),
+		Index: loc.Index,
+		ID:    loc.ID,
+	}
+
+	if loc.Block != nil {
+		cursor.Block = loc.Block.Index
+	}
+
+	return cursor
+}
+
+// QueryTransactions returns up to limit transactions selected by the filter,
+// in the order of the chain followed by the pending ones. If after is not nil,
+// it starts after the transaction at this position. It also tells if there
+// are more transactions to fetch.
+func (b *Blockchain) QueryTransactions(filter TransactionFilter, after *TxCursor,
+	limit int) ([]*TxLocation, bool, error) {
+
+	locations := b.transactionLocations()
+
+	start := 0
+	if after != nil {
+		start = -1
+		for i, loc := range locations {
+			if b.CursorOf(loc) == *after {
+				start = i + 1
+				break
+			}
+		}
+
+		if start < 0 {
+			return nil, false, xerrors.Errorf("transaction %s not found at "+
+				"position %d in block %d", after.ID, after.Index, after.Block)
+		}
+	}
+
+	result := make([]*TxLocation, 0, limit)
+
+	for _, loc := range locations[start:] {
+		if !filter.Match(loc.Tx) {
+			continue
+		}
+
+		if len(result) == limit {
+			return result, true, nil
+		}
+
+		result = append(result, loc)
+	}
+
+	return result, false, nil
+}
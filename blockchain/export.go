@@ -0,0 +1,192 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// Format is the file format of an exported chain
+type Format string
+
+const (
+	// JSONLines writes one block per line, in JSON
+	JSONLines Format = "jsonl"
+	// Binary writes a header followed by the binary encoding of each block,
+	// preceded by its length as an unsigned varint
+	Binary Format = "binary"
+)
+
+// binaryMagic starts the chains exported in the binary format. It is followed
+// by the version of the format.
+var binaryMagic = []byte("DUMMYBC")
+
+// binaryVersion is the version of the binary format written by ExportChain
+const binaryVersion = 1
+
+// maxLine is the maximum size of an exported block, in any format
+const maxLine = 16 << 20
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case JSONLines, Binary:
+		return Format(name), nil
+	default:
+		return "", xerrors.Errorf("unknown format '%s', expected %s or %s",
+			name, JSONLines, Binary)
+	}
+}
+
+// FileExtension returns the extension of the files in the format
+func (f Format) FileExtension() string {
+	if f == Binary {
+		return ".bin"
+	}
+
+	return ".jsonl"
+}
+
+// ExportChain writes the chain in the given format
+func ExportChain(w io.Writer, chain []*Block, format Format) error {
+	if format != Binary {
+		return writeJSONLines(w, chain)
+	}
+
+	header := append(append([]byte{}, binaryMagic...), binaryVersion)
+
+	_, err := w.Write(header)
+	if err != nil {
+		return xerrors.Errorf("failed to write header: %v", err)
+	}
+
+	var length [binary.MaxVarintLen64]byte
+
+	for _, block := range chain {
+		buf, err := block.MarshalBinary()
+		if err != nil {
+			return xerrors.Errorf("failed to encode block %d: %v", block.Index, err)
+		}
+
+		n := binary.PutUvarint(length[:], uint64(len(buf)))
+
+		_, err = w.Write(append(length[:n], buf...))
+		if err != nil {
+			return xerrors.Errorf("failed to write block %d: %v", block.Index, err)
+		}
+	}
+
+	return nil
+}
+
+// ImportChain reads a chain written by ExportChain, in any format. The chain
+// is not validated: use SetChain to replace the chain of a node by it.
+func ImportChain(r io.Reader) ([]*Block, error) {
+	buffered := bufio.NewReader(r)
+
+	header, err := buffered.Peek(len(binaryMagic) + 1)
+	if err != nil || !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		return readJSONLines(buffered)
+	}
+
+	version := header[len(binaryMagic)]
+	buffered.Discard(len(header))
+
+	if version != binaryVersion {
+		return nil, xerrors.Errorf("unsupported binary version %d", version)
+	}
+
+	return readBinary(buffered)
+}
+
+func writeJSONLines(w io.Writer, chain []*Block) error {
+	encoder := json.NewEncoder(w)
+
+	for _, block := range chain {
+		err := encoder.Encode(block)
+		if err != nil {
+			return xerrors.Errorf("failed to write block %d: %v", block.Index, err)
+		}
+	}
+
+	return nil
+}
+
+func readJSONLines(r io.Reader) ([]*Block, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	chain := make([]*Block, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var block Block
+
+		err := json.Unmarshal(scanner.Bytes(), &block)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read block on line %d: %v", line, err)
+		}
+
+		chain = append(chain, &block)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read chain: %v", err)
+	}
+
+	if len(chain) == 0 {
+		return nil, xerrors.Errorf("no block found")
+	}
+
+	return chain, nil
+}
+
+func readBinary(r *bufio.Reader) ([]*Block, error) {
+	chain := make([]*Block, 0)
+
+	for {
+		length, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read length of block %d: %v",
+				len(chain), err)
+		}
+
+		if length > maxLine {
+			return nil, xerrors.Errorf("block %d is too big: %d bytes",
+				len(chain), length)
+		}
+
+		buf := make([]byte, length)
+
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read block %d: %v", len(chain), err)
+		}
+
+		var block Block
+
+		err = block.UnmarshalBinary(buf)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read block %d: %v", len(chain), err)
+		}
+
+		chain = append(chain, &block)
+	}
+
+	if len(chain) == 0 {
+		return nil, xerrors.Errorf("no block found")
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,192 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// GenesisSender is the sender of the initial allocations
+const GenesisSender = "genesis"
+
+// DefaultGenesis returns the genesis of the nodes started without a genesis
+// file: a proof of work with no allocation. Its timestamp is fixed so that
+// every node has the same genesis block.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		ChainID:    "dummy",
+		Name:       "Dummy network",
+		Timestamp:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Consensus:  "pow",
+		Difficulty: "0000",
+	}
+}
+
+// LoadGenesis reads a genesis file. The missing fields are taken from the
+// default genesis.
+func LoadGenesis(path string) (*Genesis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open genesis: %v", err)
+	}
+	defer f.Close()
+
+	genesis := DefaultGenesis()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(genesis)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode genesis: %v", err)
+	}
+
+	err = genesis.Validate()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid genesis: %v", err)
+	}
+
+	return genesis, nil
+}
+
+// Genesis is the configuration of a network, from which every node creates
+// the same genesis block. The nodes refuse the chains of another genesis.
+type Genesis struct {
+	// ChainID identifies the network. The transactions are signed with it.
+	ChainID string
+	// Name is the name of the network shown in the GUI
+	Name      string
+	Timestamp time.Time
+	// Consensus is the consensus engine: pow, poa, or pos
+	Consensus string
+	// Difficulty is the prefix of the hashes, for the proof of work
+	Difficulty string
+	// Validators are the initial validators' public keys, for the proof of
+	// authority
+	Validators []string
+	// Stakes are the initial stakes by public key, for the proof of stake
+	Stakes map[string]int
+	// SlotDuration is the duration of a slot, ie. "10s", for the proof of
+	// stake
+	SlotDuration string
+	// Alloc gives the initial balance of some addresses
+	Alloc map[string]int
+}
+
+// Validate checks that the genesis gives a valid consensus
+func (g *Genesis) Validate() error {
+	if g.ChainID == "" {
+		return xerrors.Errorf("empty chain ID")
+	}
+
+	for address, amount := range g.Alloc {
+		if amount <= 0 {
+			return xerrors.Errorf("allocation of %s must be positive: %d",
+				address, amount)
+		}
+	}
+
+	_, err := g.NewConsensus("")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewConsensus returns the consensus engine of the genesis. privKey is the
+// hex-encoded key of the node, if it is a validator or a stakeholder.
+func (g *Genesis) NewConsensus(privKey string) (Consensus, error) {
+	switch g.Consensus {
+	case "pow":
+		for _, c := range g.Difficulty {
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return nil, xerrors.Errorf("difficulty must be hexadecimal: %s",
+					g.Difficulty)
+			}
+		}
+
+		return NewProofOfWork(g.Difficulty), nil
+	case "poa":
+		return NewProofOfAuthority(g.Validators, privKey)
+	case "pos":
+		slotDuration, err := time.ParseDuration(g.SlotDuration)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid slot duration: %v", err)
+		}
+
+		return NewProofOfStake(g.Stakes, slotDuration, privKey)
+	default:
+		return nil, xerrors.Errorf("unknown consensus '%s'", g.Consensus)
+	}
+}
+
+// Hash returns the hash of the configuration, which is the previous hash of
+// the genesis block: the genesis block changes with any field.
+func (g *Genesis) Hash() Hash {
+	e := &encoder{}
+	e.WriteByte(EncodingVersion)
+	e.string(g.ChainID)
+	e.string(g.Name)
+	e.int(g.Timestamp.UnixNano())
+	e.string(g.Consensus)
+	e.string(g.Difficulty)
+
+	e.uint(uint64(len(g.Validators)))
+	for _, validator := range g.Validators {
+		e.string(validator)
+	}
+
+	stakers := make([]string, 0, len(g.Stakes))
+	for staker := range g.Stakes {
+		stakers = append(stakers, staker)
+	}
+	sort.Strings(stakers)
+
+	e.uint(uint64(len(stakers)))
+	for _, staker := range stakers {
+		e.string(staker)
+		e.int(int64(g.Stakes[staker]))
+	}
+
+	e.string(g.SlotDuration)
+
+	return sha256.Sum256(e.Bytes())
+}
+
+// Magic returns the network magic, the first bytes of the hash of the genesis
+// block in hex. The nodes send it to each other to refuse the requests from
+// another network.
+func (g *Genesis) Magic() string {
+	h := g.BlockHash()
+	return hex.EncodeToString(h[:4])
+}
+
+// BlockHash returns the hash of the genesis block
+func (g *Genesis) BlockHash() Hash {
+	// the encoding of a block never fails
+	h, _ := g.Block().Hash()
+	return h
+}
+
+// Block returns the genesis block. It contains a transaction from
+// GenesisSender for each allocation, sorted by address.
+func (g *Genesis) Block() *Block {
+	addresses := make([]string, 0, len(g.Alloc))
+	for address := range g.Alloc {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	txs := make([]*Transaction, len(addresses))
+	for i, address := range addresses {
+		txs[i] = NewTransaction(GenesisSender, address, g.Alloc[address])
+	}
+
+	return NewBlock(0, g.Timestamp, 0, g.Hash(), txs)
+}
@@ -0,0 +1,317 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"dummy-blockchain/logs"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ProtocolVersion is the version of the protocol between the nodes. The nodes
+// of another version are rejected.
+const ProtocolVersion = 1
+
+// maxRejectedNodes is the number of rejected nodes kept for the GUI
+const maxRejectedNodes = 20
+
+const (
+	// FeatureCanonicalHash is the hash of the blocks and transactions on
+	// their binary encoding
+	FeatureCanonicalHash = "canonical-hash"
+	// FeatureChainIDSignature is the signature of the transactions with the
+	// chain ID
+	FeatureChainIDSignature = "chain-id-signature"
+	// FeatureMultisig is the exchange of partial transactions
+	FeatureMultisig = "multisig"
+	// FeatureExport is the export of the chain in JSON lines or binary
+	FeatureExport = "export"
+)
+
+// Features are the features supported by the node, sent in the handshake
+var Features = []string{FeatureCanonicalHash, FeatureChainIDSignature,
+	FeatureMultisig, FeatureExport}
+
+// RequiredFeatures are the features without which two nodes disagree on the
+// chain. The nodes missing one of them are rejected.
+var RequiredFeatures = []string{FeatureCanonicalHash, FeatureChainIDSignature}
+
+// Handshake introduces a node to another one, before they add each other
+type Handshake struct {
+	Version     int
+	ChainID     string
+	GenesisHash Hash
+	// Height is the length of the best chain of the node
+	Height  int
+	Address string
+	// Listen is the host and port of the node, nil if it cannot be reached
+	Listen   *Node `json:",omitempty"`
+	Features []string
+}
+
+// RejectedNode is a node refused by the handshake
+type RejectedNode struct {
+	Node   *Node
+	Reason string
+	Time   time.Time
+}
+
+// NewHandshake returns the handshake of the node
+func (b *Blockchain) NewHandshake() *Handshake {
+	return &Handshake{
+		Version:     ProtocolVersion,
+		ChainID:     b.Genesis.ChainID,
+		GenesisHash: b.Genesis.BlockHash(),
+		Height:      len(b.Chain),
+		Address:     b.Address,
+		Listen:      b.Listen,
+		Features:    Features,
+	}
+}
+
+// CheckHandshake returns the features shared with the node of the handshake,
+// or an error explaining why the node is incompatible.
+func (b *Blockchain) CheckHandshake(h *Handshake) ([]string, error) {
+	if h.Version != ProtocolVersion {
+		return nil, xerrors.Errorf("protocol version %d instead of %d",
+			h.Version, ProtocolVersion)
+	}
+
+	if h.ChainID != b.Genesis.ChainID {
+		return nil, xerrors.Errorf("chain '%s' instead of '%s'", h.ChainID,
+			b.Genesis.ChainID)
+	}
+
+	genesisHash := b.Genesis.BlockHash()
+	if h.GenesisHash != genesisHash {
+		return nil, xerrors.Errorf("genesis %s instead of %s", h.GenesisHash,
+			genesisHash)
+	}
+
+	if h.Address == b.Address {
+		return nil, xerrors.Errorf("the node is itself")
+	}
+
+	supported := make(map[string]bool)
+	for _, feature := range h.Features {
+		supported[feature] = true
+	}
+
+	for _, feature := range RequiredFeatures {
+		if !supported[feature] {
+			return nil, xerrors.Errorf("feature '%s' not supported", feature)
+		}
+	}
+
+	shared := make([]string, 0, len(Features))
+	for _, feature := range Features {
+		if supported[feature] {
+			shared = append(shared, feature)
+		}
+	}
+
+	return shared, nil
+}
+
+// Connect sends the handshake of the node to another node, and adds it to the
+// list of nodes if they are compatible. Otherwise the node is added to the
+// rejected ones, with the reason returned as error.
+func (b *Blockchain) Connect(ctx context.Context, node *Node) error {
+	h, err := b.callHandshake(ctx, http.MethodPost, node)
+	if err != nil {
+		b.reject(node, err.Error())
+		return err
+	}
+
+	features, err := b.CheckHandshake(h)
+	if err != nil {
+		b.reject(node, err.Error())
+		return err
+	}
+
+	b.AddNode(&Node{
+		Host:     node.Host,
+		Port:     node.Port,
+		Version:  h.Version,
+		Height:   h.Height,
+		Features: features,
+	})
+
+	return nil
+}
+
+// AcceptHandshake answers the handshake of another node, received from
+// remoteHost, with the one of the node. An incompatible node is rejected with
+// the reason returned as error. A compatible node is added back if it gave its
+// listen address, once the node there answers with the same address, so that
+// a wrong or stale address is not added. The handshakes are public: this does
+// not prove that the address belongs to the node. A listen address that is not
+// verified is rejected, but the handshake is still answered.
+func (b *Blockchain) AcceptHandshake(ctx context.Context, h *Handshake,
+	remoteHost string) (*Handshake, error) {
+
+	features, err := b.CheckHandshake(h)
+	if err != nil {
+		if h.Listen != nil {
+			b.reject(h.Listen, err.Error())
+		}
+
+		return nil, err
+	}
+
+	if h.Listen == nil {
+		return b.NewHandshake(), nil
+	}
+
+	listen := listenAddress(h.Listen, remoteHost)
+
+	err = b.verifyListen(ctx, h, listen)
+	if err != nil {
+		err = xerrors.Errorf("listen address %s not verified: %v", listen, err)
+		logs.FromContext(ctx).Warn("node not added back", "error", err)
+		b.reject(listen, err.Error())
+
+		return b.NewHandshake(), nil
+	}
+
+	b.AddNode(&Node{
+		Host:     listen.Host,
+		Port:     listen.Port,
+		Version:  h.Version,
+		Height:   h.Height,
+		Features: features,
+	})
+
+	return b.NewHandshake(), nil
+}
+
+// listenAddress returns the listen address of a handshake received from
+// remoteHost. A loopback host only means the node did not know its address,
+// ie. it was started without -advertise-addr: unless the node is on the same
+// machine, it is reached at the host it sent the handshake from.
+func listenAddress(listen *Node, remoteHost string) *Node {
+	if remoteHost == "" || !isLoopback(listen.Host) || isLoopback(remoteHost) {
+		return listen
+	}
+
+	return NewNode(remoteHost, listen.Port)
+}
+
+// isLoopback tells if the host is empty or refers to the local machine
+func isLoopback(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// verifyListen gets the handshake of the node at the listen address, without
+// sending ours, and checks that it is the node of the handshake.
+func (b *Blockchain) verifyListen(ctx context.Context, h *Handshake, listen *Node) error {
+	listened, err := b.callHandshake(ctx, http.MethodGet, listen)
+	if err != nil {
+		return err
+	}
+
+	if listened.Address != h.Address {
+		return xerrors.Errorf("node '%s' instead of '%s'", listened.Address,
+			h.Address)
+	}
+
+	_, err = b.CheckHandshake(listened)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// callHandshake gets the handshake of another node. With POST, the handshake
+// of the node is sent so that the other node adds it back, and with GET the
+// other node only answers.
+func (b *Blockchain) callHandshake(ctx context.Context, method string, node *Node) (*Handshake, error) {
+	url := node.GetHTTP() + "/handshake"
+
+	var body bytes.Buffer
+
+	if method == http.MethodPost {
+		err := json.NewEncoder(&body).Encode(b.NewHandshake())
+		if err != nil {
+			return nil, xerrors.Errorf("failed to marshal handshake: %v", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, &body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request: %v", err)
+	}
+
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	b.SetNetwork(req.Header)
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to call on '%s': %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Message string
+			}
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&errResp)
+		if err != nil || errResp.Error.Message == "" {
+			return nil, xerrors.Errorf("wrong status code: %s", resp.Status)
+		}
+
+		return nil, xerrors.Errorf("refused by the node: %s", errResp.Error.Message)
+	}
+
+	var h Handshake
+	err = json.NewDecoder(resp.Body).Decode(&h)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode handshake: %v", err)
+	}
+
+	return &h, nil
+}
+
+// reject adds a node to the rejected ones, replacing the previous rejection of
+// the same node. Only the last maxRejectedNodes are kept.
+func (b *Blockchain) reject(node *Node, reason string) {
+	b.removeRejected(node)
+
+	b.RejectedNodes = append(b.RejectedNodes, &RejectedNode{
+		Node:   &Node{Host: node.Host, Port: node.Port},
+		Reason: reason,
+		Time:   b.Clock.Now(),
+	})
+
+	if len(b.RejectedNodes) > maxRejectedNodes {
+		b.RejectedNodes = b.RejectedNodes[len(b.RejectedNodes)-maxRejectedNodes:]
+	}
+
+	b.Events.Publish(EventPeerChanged, b.Snapshot().Nodes)
+}
+
+// removeRejected removes the rejection of a node, if any
+func (b *Blockchain) removeRejected(node *Node) {
+	for i, rejected := range b.RejectedNodes {
+		if rejected.Node.String() == node.String() {
+			b.RejectedNodes = append(b.RejectedNodes[:i], b.RejectedNodes[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package blockchain
+
+import "testing"
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		listen     *Node
+		remoteHost string
+		expected   string
+	}{
+		{NewNode("localhost", 8081), "127.0.0.1", "localhost:8081"},
+		{NewNode("localhost", 8081), "::1", "localhost:8081"},
+		{NewNode("localhost", 8081), "", "localhost:8081"},
+		// a node started without -advertise-addr on another machine
+		{NewNode("localhost", 8081), "192.168.1.12", "192.168.1.12:8081"},
+		{NewNode("127.0.0.1", 8081), "192.168.1.12", "192.168.1.12:8081"},
+		{NewNode("", 8081), "192.168.1.12", "192.168.1.12:8081"},
+		// an advertised address is kept, even if behind another host
+		{NewNode("node-b.lan", 8081), "192.168.1.12", "node-b.lan:8081"},
+		{NewNode("192.168.1.13", 8081), "192.168.1.12", "192.168.1.13:8081"},
+	}
+
+	for _, test := range tests {
+		listen := listenAddress(test.listen, test.remoteHost)
+		if listen.String() != test.expected {
+			t.Errorf("%s from '%s': %s instead of %s", test.listen,
+				test.remoteHost, listen, test.expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// NewKeyPair generates a new ed25519 key pair from the entropy, or from
+// crypto/rand if it is nil. Keys are hex-encoded.
+func NewKeyPair(entropy io.Reader) (pubKey, privKey string, err error) {
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+
+	pub, priv, err := ed25519.GenerateKey(entropy)
+	if err != nil {
+		return "", "", xerrors.Errorf("failed to generate key: %v", err)
+	}
+
+	return hex.EncodeToString(pub), hex.EncodeToString(priv), nil
+}
+
+// PubKeyHash returns the hex-encoded hash of a hex-encoded public key. This is
+// what the HASH opcode computes.
+func PubKeyHash(pubKey string) (string, error) {
+	buf, err := hex.DecodeString(pubKey)
+	if err != nil {
+		return "", xerrors.Errorf("failed to decode public key: %v", err)
+	}
+
+	h := sha256.Sum256(buf)
+
+	return hex.EncodeToString(h[:]), nil
+}
+
+// ParsePrivateKey decodes a hex-encoded private key
+func ParsePrivateKey(privKey string) (ed25519.PrivateKey, error) {
+	buf, err := hex.DecodeString(privKey)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode private key: %v", err)
+	}
+
+	if len(buf) != ed25519.PrivateKeySize {
+		return nil, xerrors.Errorf("wrong private key size: %d", len(buf))
+	}
+
+	return ed25519.PrivateKey(buf), nil
+}
+
+// PublicKeyOf returns the hex-encoded public key of a private key
+func PublicKeyOf(privKey ed25519.PrivateKey) string {
+	return hex.EncodeToString(privKey.Public().(ed25519.PublicKey))
+}
+
+// Sign returns the hex-encoded signature of the transaction's signature hash
+// on the network with the given chain ID, with the given hex-encoded private
+// key.
+func (t Transaction) Sign(privKey, chainID string) (string, error) {
+	buf, err := ParsePrivateKey(privKey)
+	if err != nil {
+		return "", xerrors.Errorf("failed to parse private key: %v", err)
+	}
+
+	sigHash, err := t.SigHash(chainID)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get signature hash: %v", err)
+	}
+
+	return hex.EncodeToString(ed25519.Sign(buf, sigHash[:])), nil
+}
+
+// NewAddress generates a random address from the entropy, or from crypto/rand
+// if it is nil. It is formatted as a UUID without the dashes.
+func NewAddress(entropy io.Reader) (string, error) {
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+
+	var buf [16]byte
+	_, err := io.ReadFull(entropy, buf[:])
+	if err != nil {
+		return "", xerrors.Errorf("failed to read entropy: %v", err)
+	}
+
+	// version 4 and variant bits of a random UUID
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+
+	return hex.EncodeToString(buf[:]), nil
+}
@@ -2,69 +2,248 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"crypto/rand"
+	"dummy-blockchain/logs"
 	"encoding/json"
-	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/xerrors"
 )
 
-// NewBlockchain creates a new blockchain
-func NewBlockchain(address string) *Blockchain {
+// NewBlockchain creates a new blockchain starting with the genesis block.
+// genesis defaults to DefaultGenesis, clock to the system clock, and entropy
+// to crypto/rand.
+func NewBlockchain(address string, genesis *Genesis, consensus Consensus,
+	clock Clock, entropy io.Reader) *Blockchain {
+
+	if genesis == nil {
+		genesis = DefaultGenesis()
+	}
+
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+
+	pos, ok := consensus.(*ProofOfStake)
+	if ok && pos.Clock == nil {
+		pos.Clock = clock
+	}
+
+	if ok && pos.ChainID == "" {
+		pos.ChainID = genesis.ChainID
+	}
+
+	poa, ok := consensus.(*ProofOfAuthority)
+	if ok && poa.ChainID == "" {
+		poa.ChainID = genesis.ChainID
+	}
+
 	blockchain := &Blockchain{
 		Chain:        make([]*Block, 0),
 		Transactions: make([]*Transaction, 0),
 		Nodes:        make([]*Node, 0),
 		Address:      address,
+		Consensus:    consensus,
+		Events:       NewEventBus(),
+		Client:       http.DefaultClient,
+		Clock:        clock,
+		Entropy:      entropy,
+		Genesis:      genesis,
+
+		PartialTransactions: make([]*PartialTransaction, 0),
+		RejectedNodes:       make([]*RejectedNode, 0),
 	}
 
-	blockchain.CreateBlock(0, [32]byte{})
+	blockchain.appendBlock(genesis.Block())
 
 	return blockchain
 }
 
 // Blockchain represents a node holding a chain of blocks.
 type Blockchain struct {
+	// lock is held while Chain, Transactions and Nodes are replaced. The
+	// goroutines that do not change them read them with Snapshot.
+	lock sync.RWMutex
+
 	Chain        []*Block
 	Transactions []*Transaction
 	Nodes        []*Node
 	Address      string
+
+	// PartialTransactions are the multisig transactions waiting for more
+	// signatures.
+	PartialTransactions []*PartialTransaction
+
+	// RejectedNodes are the last nodes refused by the handshake, with the
+	// reason.
+	RejectedNodes []*RejectedNode
+
+	// Listen is the host and port at which the other nodes reach the node,
+	// sent in the handshake so that they add it back. It is nil if unknown.
+	Listen *Node `json:"-"`
+
+	// Consensus seals and verifies the blocks. It is not shared with the
+	// other nodes.
+	Consensus Consensus `json:"-"`
+
+	// Events publishes what happens on the node, ie. to update the GUI.
+	Events *EventBus `json:"-"`
+
+	// Client is used to contact the other nodes
+	Client *http.Client `json:"-"`
+
+	// Clock gives the timestamp of the blocks
+	Clock Clock `json:"-"`
+
+	// Entropy is the source of the random bytes, ie. for the keys
+	Entropy io.Reader `json:"-"`
+
+	// Genesis is the configuration of the network
+	Genesis *Genesis `json:"-"`
+}
+
+// Snapshot is a copy of the chain, the pending transactions and the nodes,
+// taken at once
+type Snapshot struct {
+	Chain        []*Block
+	Transactions []*Transaction
+	Nodes        []*Node
+}
+
+// Snapshot returns a copy of the chain, the pending transactions and the
+// nodes, which stays the same while the node changes. The events, the JSON-RPC
+// server and the attacker read them through it.
+func (b *Blockchain) Snapshot() Snapshot {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return Snapshot{
+		Chain:        append([]*Block(nil), b.Chain...),
+		Transactions: append([]*Transaction(nil), b.Transactions...),
+		Nodes:        append([]*Node(nil), b.Nodes...),
+	}
 }
 
-// CreateBlock creates a block and appends it to the chain
+// CreateBlock creates a block and appends it to the chain. Pending
+// transactions whose lock time is not yet reached are kept for a later block.
 func (b *Blockchain) CreateBlock(proof int, prevHash [32]byte) *Block {
-	block := NewBlock(len(b.Chain), proof, prevHash, b.Transactions)
+	block := b.prepareBlock(prevHash)
+	block.Proof = proof
 
-	b.Chain = append(b.Chain, block)
-	b.Transactions = make([]*Transaction, 0)
+	b.appendBlock(block)
 
 	return block
 }
 
-// GetPreviousBlock returns the last block stored. This function panics if the
-// chain is empty.
-func (b *Blockchain) GetPreviousBlock() *Block {
-	return b.Chain[len(b.Chain)-1]
+// MineBlock creates a block with the pending transactions and a transaction
+// fee sent to feeReceiver, seals it with the consensus engine, and appends it
+// to the chain. The context carries the logger.
+func (b *Blockchain) MineBlock(ctx context.Context, feeReceiver string) (*Block, error) {
+	logger := logs.FromContext(ctx)
+
+	previousBlock := b.GetPreviousBlock()
+	previousHash, err := previousBlock.Hash()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get hash: %v", err)
+	}
+
+	block := b.prepareBlock(previousHash)
+
+	// transaction fee, sending money to the fee receiver
+	block.Transactions = append(block.Transactions,
+		NewFeeTransaction(b.Address, feeReceiver, block.Index))
+
+	logger.Debug("sealing block", "index", block.Index,
+		"transactions", len(block.Transactions), "consensus", b.Consensus.Name())
+
+	start := time.Now()
+
+	err = b.Consensus.Seal(b.Chain, block)
+	if err != nil {
+		logger.Warn("failed to seal block", "index", block.Index, "error", err)
+		return nil, xerrors.Errorf("failed to seal block: %v", err)
+	}
+
+	b.appendBlock(block)
+
+	logger.Info("block mined", "index", block.Index, "proof", block.Proof,
+		"duration", time.Since(start))
+
+	return block, nil
 }
 
-// ProofOfWork calculates the right nounce, ie. the proof
-func (b *Blockchain) ProofOfWork(prevProof int) int {
-	newProof := 0
+// prepareBlock returns a new block, not yet appended, containing the pending
+// transactions whose lock time is reached. The transactions that no longer
+// unlock their inputs, ie. after a reorg, are left out.
+func (b *Blockchain) prepareBlock(prevHash [32]byte) *Block {
+	block := NewBlock(len(b.Chain), b.Clock.Now(), 0, prevHash, nil)
 
-	for {
-		hashOperation := sha256.Sum256([]byte(fmt.Sprintf("%d",
-			newProof*newProof-prevProof*prevProof)))
+	ctx := blockContext(block, b.Genesis.ChainID)
+	outputs := b.chainOutputs()
+	block.Transactions = make([]*Transaction, 0, len(b.Transactions))
 
-		if strings.HasPrefix(hex.EncodeToString(hashOperation[:]), "0000") {
-			break
+	for _, tx := range b.Transactions {
+		err := b.checkTransaction(block.Transactions, tx)
+		if err != nil {
+			continue
+		}
+
+		err = outputs.apply(tx, ctx)
+		if err == nil {
+			block.Transactions = append(block.Transactions, tx)
 		}
-		newProof++
 	}
 
-	return newProof
+	return block
+}
+
+// checkTransaction runs the check of the consensus, if any, on a transaction
+// following the previous ones in the next block.
+func (b *Blockchain) checkTransaction(previous []*Transaction, tx *Transaction) error {
+	checker, ok := b.Consensus.(TransactionChecker)
+	if !ok {
+		return nil
+	}
+
+	return checker.CheckTransaction(b.Chain, previous, tx)
+}
+
+// appendBlock appends the block to the chain and removes its transactions from
+// the pending ones.
+func (b *Blockchain) appendBlock(block *Block) {
+	included := make(map[*Transaction]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		included[tx] = true
+	}
+
+	pending := make([]*Transaction, 0)
+	for _, tx := range b.Transactions {
+		if !included[tx] {
+			pending = append(pending, tx)
+		}
+	}
+
+	b.lock.Lock()
+	b.Chain = append(b.Chain, block)
+	b.Transactions = pending
+	b.lock.Unlock()
+
+	b.Events.Publish(EventBlockAdded, block)
+}
+
+// GetPreviousBlock returns the last block stored. This function panics if the
+// chain is empty.
+func (b *Blockchain) GetPreviousBlock() *Block {
+	return b.Chain[len(b.Chain)-1]
 }
 
 // IsCHainValid checks that the given chain is valid
@@ -75,7 +254,7 @@ func (b *Blockchain) IsCHainValid(blocks []*Block) (bool, error) {
 
 	prevBlock := blocks[0]
 
-	for _, block := range blocks[1:] {
+	for i, block := range blocks[1:] {
 		// 1: check the prev hash: the prevHash of the block should be the same
 		// as the hash of the previous block
 		prevHash, err := prevBlock.Hash()
@@ -86,71 +265,535 @@ func (b *Blockchain) IsCHainValid(blocks []*Block) (bool, error) {
 			return false, nil
 		}
 
-		// 2: check the proof: we apply the same hashOperation as in the
-		// ProofOfWork function and check if it returns a correct hash
-		prevProof := prevBlock.Proof
-		proof := block.Proof
-		hashOperation := sha256.Sum256([]byte(fmt.Sprintf("%d", proof*proof-
-			prevProof*prevProof)))
-		if !strings.HasPrefix(hex.EncodeToString(hashOperation[:]), "0000") {
+		// 2: check the consensus fields, ie. the proof for the proof of work
+		err = b.Consensus.Verify(blocks[:i+1], block)
+		if err != nil {
 			return false, nil
 		}
 
 		prevBlock = block
 	}
 
+	// 3: check the transactions: their lock time must be reached and every
+	// input must reference an unspent output of a previous transaction and
+	// unlock it.
+	err := verifyTransactions(blocks, b.Genesis.ChainID)
+	if err != nil {
+		return false, nil
+	}
+
 	return true, nil
 }
 
-// AddTransaction adds a new transaction to the list of transactions. Returns
-// the block index of the block that will contain the transaction.
-func (b *Blockchain) AddTransaction(t *Transaction) int {
+// verifyTransactions checks the lock time of all the transactions and executes
+// the scripts of their inputs against the outputs they spend, signed for the
+// given chain ID.
+func verifyTransactions(blocks []*Block, chainID string) error {
+	outputs := make(unspentOutputs)
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			err := outputs.apply(tx, blockContext(block, chainID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockContext returns the script context of the transactions of a block
+func blockContext(block *Block, chainID string) ScriptContext {
+	return ScriptContext{
+		Height:  block.Index,
+		Time:    block.Timestamp / int64(time.Second),
+		ChainID: chainID,
+	}
+}
+
+// unspentOutputs are the outputs not yet spent, by ID of their transaction.
+// Identical transactions, ie. the same payment sent twice, have the same ID:
+// each copy is an output that can be spent once.
+type unspentOutputs map[Hash][]*Transaction
+
+// apply checks a transaction included in a block of the given context, spends
+// the outputs referenced by its inputs and adds its own output. The outputs
+// are left untouched if the transaction is invalid.
+func (u unspentOutputs) apply(tx *Transaction, ctx ScriptContext) error {
+	if strings.HasPrefix(tx.Receiver, MultisigPrefix) {
+		address, err := ParseMultisigScript(tx.LockScript)
+		if err != nil || address.String() != tx.Receiver {
+			return xerrors.Errorf("output not locked to %s", tx.Receiver)
+		}
+	}
+
+	ctx.Tx = tx
+
+	if !tx.IsFinal(ctx.Height, ctx.Time) {
+		return xerrors.Errorf("transaction locked until %d", tx.LockTime)
+	}
+
+	spent := make(map[Hash]int)
+
+	for _, input := range tx.Inputs {
+		copies := u[input.PrevTx]
+		if len(copies) <= spent[input.PrevTx] {
+			return xerrors.Errorf("output %s not found or already spent",
+				input.PrevTx)
+		}
+
+		prevTx := copies[spent[input.PrevTx]]
+
+		_, err := ExecuteScripts(input.UnlockScript, prevTx.LockScript, ctx)
+		if err != nil {
+			return xerrors.Errorf("failed to unlock %s: %v", input.PrevTx, err)
+		}
+
+		spent[input.PrevTx]++
+	}
+
+	id, err := tx.ID()
+	if err != nil {
+		return xerrors.Errorf("failed to get tx id: %v", err)
+	}
+
+	for prevTx, n := range spent {
+		u[prevTx] = u[prevTx][n:]
+		if len(u[prevTx]) == 0 {
+			delete(u, prevTx)
+		}
+	}
+
+	u[id] = append(u[id], tx)
+
+	return nil
+}
+
+// chainOutputs returns the outputs of the chain that are not spent yet
+func (b *Blockchain) chainOutputs() unspentOutputs {
+	return outputsOf(b.Chain, b.Genesis.ChainID)
+}
+
+// outputsOf returns the outputs of a valid chain that are not spent yet
+func outputsOf(chain []*Block, chainID string) unspentOutputs {
+	outputs := make(unspentOutputs)
+
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			outputs.apply(tx, blockContext(block, chainID))
+		}
+	}
+
+	return outputs
+}
+
+// nextContext returns the script context of the next block, if it was mined
+// now.
+func (b *Blockchain) nextContext() ScriptContext {
+	return ScriptContext{
+		Height:  b.GetPreviousBlock().Index + 1,
+		Time:    b.Clock.Now().Unix(),
+		ChainID: b.Genesis.ChainID,
+	}
+}
+
+// lockedContext returns the context of the first block that can include the
+// transaction, after the given one.
+func lockedContext(ctx ScriptContext, tx *Transaction) ScriptContext {
+	switch {
+	case tx.IsHeightLocked() && tx.LockTime > int64(ctx.Height):
+		ctx.Height = int(tx.LockTime)
+	case tx.IsTimeLocked() && tx.LockTime > ctx.Time:
+		ctx.Time = tx.LockTime
+	}
+
+	return ctx
+}
+
+// AddTransaction checks a new transaction and adds it to the list of
+// transactions. It must send a positive amount, which the sender has after
+// the chain and the pending transactions, unless it only carries a vote or an
+// evidence. Its inputs must reference outputs of the chain or of the pending
+// transactions that are not spent yet, and unlock them in the first block
+// that can include it. Returns the block index of the block that will contain
+// the transaction.
+func (b *Blockchain) AddTransaction(t *Transaction) (int, error) {
+	if t.Amount < 0 || t.Amount == 0 && t.Vote == nil && t.Evidence == nil {
+		return 0, xerrors.Errorf("invalid transaction: amount must be "+
+			"positive: %d", t.Amount)
+	}
+
+	balance := b.pendingBalance(t.Sender)
+	if t.Amount > 0 && t.Amount > balance {
+		return 0, xerrors.Errorf("invalid transaction: %s has %d, cannot "+
+			"send %d", t.Sender, balance, t.Amount)
+	}
+
+	next := b.nextContext()
+	outputs := b.chainOutputs()
+
+	for _, tx := range b.Transactions {
+		// the pending transactions are applied at their lock time
+		outputs.apply(tx, lockedContext(next, tx))
+	}
+
+	ctx := lockedContext(next, t)
+
+	err := outputs.apply(t, ctx)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid transaction: %v", err)
+	}
+
+	err = b.checkTransaction(b.Transactions, t)
+	if err != nil {
+		return 0, xerrors.Errorf("refused by the consensus: %v", err)
+	}
+
+	b.lock.Lock()
 	b.Transactions = append(b.Transactions, t)
+	b.lock.Unlock()
 
-	return b.GetPreviousBlock().Index + 1
+	b.Events.Publish(EventTxAdded, t)
+
+	return ctx.Height, nil
 }
 
-// AddNode adds a new node to the list of nodes
+// FindTransaction looks for a transaction in the chain and in the pending
+// transactions. Returns nil if not found.
+func (b *Blockchain) FindTransaction(id Hash) *Transaction {
+	loc := b.LocateTransaction(id)
+	if loc == nil {
+		return nil
+	}
+
+	return loc.Tx
+}
+
+// AddPartialTransaction stores a partially-signed transaction, or merges its
+// signatures if we already know it. Returns the stored partial transaction.
+func (b *Blockchain) AddPartialTransaction(p *PartialTransaction) (*PartialTransaction, error) {
+	if p.ChainID != b.Genesis.ChainID {
+		return nil, xerrors.Errorf("partial transaction for chain '%s' instead "+
+			"of '%s'", p.ChainID, b.Genesis.ChainID)
+	}
+
+	err := p.Verify()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid partial transaction: %v", err)
+	}
+
+	id, err := p.ID()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get id: %v", err)
+	}
+
+	known := b.FindPartialTransaction(id)
+	if known == nil {
+		b.PartialTransactions = append(b.PartialTransactions, p)
+		return p, nil
+	}
+
+	err = known.Merge(p)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to merge: %v", err)
+	}
+
+	return known, nil
+}
+
+// FindPartialTransaction returns the partial transaction with the given ID, or
+// nil if not found.
+func (b *Blockchain) FindPartialTransaction(id Hash) *PartialTransaction {
+	for _, p := range b.PartialTransactions {
+		pID, err := p.ID()
+		if err == nil && pID == id {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// FinalizePartialTransaction moves a fully signed partial transaction to the
+// pending transactions. Returns the block index of the block that will contain
+// the transaction.
+func (b *Blockchain) FinalizePartialTransaction(id Hash) (int, error) {
+	for i, p := range b.PartialTransactions {
+		pID, err := p.ID()
+		if err != nil || pID != id {
+			continue
+		}
+
+		tx, err := p.Finalize()
+		if err != nil {
+			return 0, xerrors.Errorf("failed to finalize: %v", err)
+		}
+
+		index, err := b.AddTransaction(tx)
+		if err != nil {
+			return 0, err
+		}
+
+		b.PartialTransactions = append(b.PartialTransactions[:i],
+			b.PartialTransactions[i+1:]...)
+
+		return index, nil
+	}
+
+	return 0, xerrors.Errorf("partial transaction %s not found", id)
+}
+
+// AddNode adds a new node to the list of nodes, without handshake. It
+// replaces the node with the same host and port, if any. Use Connect to add a
+// node of unknown network.
 func (b *Blockchain) AddNode(node *Node) {
-	b.Nodes = append(b.Nodes, node)
+	b.removeRejected(node)
+
+	b.lock.Lock()
+
+	// the nodes are copied, not changed in place, so that the previous
+	// snapshots stay the same
+	nodes := append([]*Node(nil), b.Nodes...)
+	replaced := false
+
+	for i, known := range nodes {
+		if known.String() == node.String() {
+			nodes[i] = node
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		nodes = append(nodes, node)
+	}
+
+	b.Nodes = nodes
+	b.lock.Unlock()
+
+	b.Events.Publish(EventPeerChanged, b.Snapshot().Nodes)
 }
 
 // ReplaceChain checks the chains on all the other nodes and replace the current
-// chain if it finds one longest than ours. Returns if the chain has been
-// updated or not.
-func (b *Blockchain) ReplaceChain() (bool, error) {
-	maxLen := len(b.Chain)
-	var longestChain []*Block
+// chain if it finds a valid one that the consensus prefers, ie. the longest
+// one for the proof of work. Returns if the chain has been updated or not. The
+// nodes that cannot be reached are skipped, it fails only if none can be. The
+// context carries the logger and the request ID forwarded to the other nodes.
+func (b *Blockchain) ReplaceChain(ctx context.Context) (bool, error) {
+	logger := logs.FromContext(ctx)
+
+	var bestChain []*Block
+	var lastErr error
+
+	reached := 0
 
 	for _, node := range b.Nodes {
-		url := node.GetHTTP() + "/get_chain"
-		resp, err := http.Get(url)
+		peerLogger := logger.With("peer", node.GetHTTP())
+
+		peerLogger.Debug("fetching chain")
+
+		candidate, err := b.fetchChain(ctx, node)
 		if err != nil {
-			return false, xerrors.Errorf("failed to call on '%s': %v", url, err)
+			peerLogger.Warn("failed to fetch chain", "error", err)
+			lastErr = err
+			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return false, xerrors.Errorf("wrong status code: %s", resp.Status)
-		}
+		reached++
 
-		var chainResp GetCHainResponse
-		err = json.NewDecoder(resp.Body).Decode(&chainResp)
+		peerLogger.Debug("chain fetched", "height", len(candidate))
+
+		err = b.checkGenesis(candidate)
 		if err != nil {
-			return false, xerrors.Errorf("failed to decode response: %v", err)
+			peerLogger.Warn("chain refused", "error", err)
+			continue
+		}
+
+		b.collectEvidence(candidate)
+
+		current := b.Chain
+		if bestChain != nil {
+			current = bestChain
 		}
 
-		if chainResp.Numblocks > maxLen {
-			maxLen = chainResp.Numblocks
-			longestChain = chainResp.Blockchain.Chain
+		if !b.Consensus.ForkChoice(current, candidate) {
+			continue
 		}
+
+		valid, err := b.IsCHainValid(candidate)
+		if err != nil || !valid {
+			peerLogger.Warn("invalid chain", "height", len(candidate), "error", err)
+			continue
+		}
+
+		bestChain = candidate
 	}
 
-	if longestChain != nil {
-		b.Chain = longestChain
+	if bestChain != nil {
+		reorg := b.reorgTo(bestChain)
+
+		logger.Info("chain replaced", "fork", reorg.Fork,
+			"old_height", reorg.OldHeight, "new_height", reorg.NewHeight)
+
 		return true, nil
 	}
 
+	if reached == 0 && lastErr != nil {
+		return false, lastErr
+	}
+
+	logger.Debug("chain kept", "height", len(b.Chain), "peers", len(b.Nodes))
+
 	return false, nil
 
 }
+
+// SetChain replaces the chain by a valid one with the same genesis, ie. a
+// branch mined in private or an imported chain. Unless forced, the chain must
+// be preferred to the current one by the fork choice of the consensus, as
+// when the chain is replaced by the one of another node.
+func (b *Blockchain) SetChain(chain []*Block, force bool) (Reorg, error) {
+	err := b.checkGenesis(chain)
+	if err != nil {
+		return Reorg{}, err
+	}
+
+	if !force && !b.Consensus.ForkChoice(b.Chain, chain) {
+		return Reorg{}, xerrors.Errorf("the current chain is preferred by the " +
+			"fork choice, force the replacement to roll it back")
+	}
+
+	valid, err := b.IsCHainValid(chain)
+	if err != nil {
+		return Reorg{}, xerrors.Errorf("failed to check chain: %v", err)
+	}
+
+	if !valid {
+		return Reorg{}, xerrors.Errorf("invalid chain")
+	}
+
+	return b.reorgTo(chain), nil
+}
+
+// checkGenesis returns an error if the chain does not start with our genesis
+// block, ie. if it comes from another network.
+func (b *Blockchain) checkGenesis(chain []*Block) error {
+	if len(chain) == 0 {
+		return xerrors.Errorf("chain is empty")
+	}
+
+	ours, err := b.Chain[0].Hash()
+	if err != nil {
+		return xerrors.Errorf("failed to get hash: %v", err)
+	}
+
+	theirs, err := chain[0].Hash()
+	if err != nil {
+		return xerrors.Errorf("failed to get hash: %v", err)
+	}
+
+	if ours != theirs {
+		return xerrors.Errorf("different genesis: %s instead of %s", theirs, ours)
+	}
+
+	return nil
+}
+
+// reorgTo replaces the chain and publishes the reorg
+func (b *Blockchain) reorgTo(chain []*Block) Reorg {
+	reorg := Reorg{
+		Fork:      forkIndex(b.Chain, chain),
+		OldHeight: len(b.Chain),
+		NewHeight: len(chain),
+		Head:      chain[len(chain)-1],
+	}
+
+	b.lock.Lock()
+	b.Chain = chain
+	b.lock.Unlock()
+
+	b.Events.Publish(EventReorg, reorg)
+
+	return reorg
+}
+
+// fetchChain returns the chain of another node
+func (b *Blockchain) fetchChain(ctx context.Context, node *Node) ([]*Block, error) {
+	url := node.GetHTTP() + "/get_chain"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request: %v", err)
+	}
+
+	b.SetNetwork(req.Header)
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to call on '%s': %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	err = b.CheckNetwork(resp.Header)
+	if err != nil {
+		return nil, xerrors.Errorf("refused '%s': %v", url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("wrong status code: %s", resp.Status)
+	}
+
+	var chainResp GetCHainResponse
+	err = json.NewDecoder(resp.Body).Decode(&chainResp)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode response: %v", err)
+	}
+
+	return chainResp.Blockchain.Chain, nil
+}
+
+// forkIndex returns the index of the first block that differs between the two
+// chains.
+func forkIndex(a, b []*Block) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		hashA, errA := a[i].Hash()
+		hashB, errB := b[i].Hash()
+
+		if errA != nil || errB != nil || hashA != hashB {
+			return i
+		}
+	}
+
+	if len(a) < len(b) {
+		return len(a)
+	}
+
+	return len(b)
+}
+
+// collectEvidence adds to the pending transactions the evidence of misbehavior
+// found in the candidate chain, if the consensus looks for some.
+func (b *Blockchain) collectEvidence(candidate []*Block) {
+	collector, ok := b.Consensus.(EvidenceCollector)
+	if !ok || len(candidate) == 0 {
+		return
+	}
+
+	for _, evidence := range collector.CollectEvidence(b.Chain, candidate) {
+		tx, err := NewSlashingTransaction(evidence, b.Address)
+		if err != nil {
+			continue
+		}
+
+		id, err := tx.ID()
+		if err != nil || b.FindTransaction(id) != nil {
+			continue
+		}
+
+		_, err = b.AddTransaction(tx)
+		if err != nil {
+			continue
+		}
+	}
+}
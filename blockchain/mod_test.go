@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestPeerEventHasCopyOfNodes(t *testing.T) {
+	blockchain := NewBlockchain("node", nil, NewProofOfWork("0"), nil, nil)
+
+	var published [][]*Node
+	blockchain.Events.Handle(func(e Event) {
+		if e.Type == EventPeerChanged {
+			published = append(published, e.Data.([]*Node))
+		}
+	})
+
+	blockchain.AddNode(NewNode("localhost", 8081))
+	blockchain.AddNode(NewNode("localhost", 8082))
+
+	replacement := NewNode("localhost", 8081)
+	blockchain.AddNode(replacement)
+
+	if len(published) != 3 {
+		t.Fatalf("%d events instead of 3", len(published))
+	}
+
+	// the events already published are not changed by the later nodes
+	if len(published[0]) != 1 || len(published[1]) != 2 {
+		t.Fatalf("published nodes changed: %v then %v", published[0], published[1])
+	}
+
+	if published[1][0] == replacement {
+		t.Fatal("replaced node changed in a published list")
+	}
+
+	if published[2][0] != replacement {
+		t.Fatal("node not replaced")
+	}
+}
+
+func TestSnapshotWhileMining(t *testing.T) {
+	genesis := DefaultGenesis()
+	genesis.Alloc = map[string]int{"alice": 1000}
+
+	blockchain := NewBlockchain("node", genesis, NewProofOfWork("0"), nil, nil)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			snapshot := blockchain.Snapshot()
+			for i, block := range snapshot.Chain {
+				if block.Index != i {
+					t.Errorf("block %d at index %d", block.Index, i)
+					return
+				}
+			}
+
+			blockchain.GetBalance("alice")
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		_, err := blockchain.AddTransaction(NewTransaction("alice", "bob", 1))
+		if err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+
+		blockchain.AddNode(NewNode("localhost", 8000+i))
+
+		_, err = blockchain.MineBlock(context.Background(), "owner")
+		if err != nil {
+			t.Fatalf("failed to mine block: %v", err)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	if len(blockchain.Snapshot().Chain) != 21 {
+		t.Fatalf("chain has %d blocks instead of 21", len(blockchain.Snapshot().Chain))
+	}
+}
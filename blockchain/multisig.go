@@ -0,0 +1,305 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// MultisigPrefix is the prefix of multisig addresses
+const MultisigPrefix = "msig"
+
+// NewMultisigAddress returns a new M-of-N multisig address
+func NewMultisigAddress(m int, pubKeys []string) (*MultisigAddress, error) {
+	if m < 1 || m > len(pubKeys) {
+		return nil, xerrors.Errorf("M should be between 1 and %d: %d",
+			len(pubKeys), m)
+	}
+
+	keys := make([]string, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		buf, err := hex.DecodeString(pubKey)
+		if err != nil || len(buf) != ed25519.PublicKeySize {
+			return nil, xerrors.Errorf("invalid public key '%s'", pubKey)
+		}
+		keys[i] = strings.ToLower(pubKey)
+	}
+
+	return &MultisigAddress{
+		M:       m,
+		PubKeys: keys,
+	}, nil
+}
+
+// ParseMultisigScript returns the multisig address whose locking script is
+// the given one.
+func ParseMultisigScript(script Script) (*MultisigAddress, error) {
+	tokens := script.Tokens()
+	if len(tokens) < 4 || tokens[len(tokens)-1] != opCheckMultiSig {
+		return nil, xerrors.Errorf("not a multisig script: %s", script)
+	}
+
+	m, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read M: %v", err)
+	}
+
+	n, err := strconv.Atoi(tokens[len(tokens)-2])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read N: %v", err)
+	}
+
+	keyTokens := tokens[1 : len(tokens)-2]
+	if len(keyTokens) != n {
+		return nil, xerrors.Errorf("expected %d public keys, got %d", n,
+			len(keyTokens))
+	}
+
+	pubKeys := make([]string, n)
+	for i, token := range keyTokens {
+		pubKeys[i] = strings.Trim(token, "<>")
+	}
+
+	return NewMultisigAddress(m, pubKeys)
+}
+
+// MultisigAddress is an address whose funds can be spent with the signatures
+// of M out of its N public keys.
+type MultisigAddress struct {
+	M       int
+	PubKeys []string
+}
+
+// Script returns the locking script of the address
+func (a MultisigAddress) Script() Script {
+	tokens := make([]string, 0, len(a.PubKeys)+3)
+	tokens = append(tokens, strconv.Itoa(a.M))
+
+	for _, pubKey := range a.PubKeys {
+		tokens = append(tokens, "<"+pubKey+">")
+	}
+
+	tokens = append(tokens, strconv.Itoa(len(a.PubKeys)), opCheckMultiSig)
+
+	return Script(strings.Join(tokens, " "))
+}
+
+// String returns the address, which is the prefixed hash of its script
+func (a MultisigAddress) String() string {
+	h := sha256.Sum256([]byte(a.Script()))
+	return MultisigPrefix + hex.EncodeToString(h[:20])
+}
+
+// NewPartialTransaction returns a new partially-signed transaction spending
+// outputs locked to the given multisig address, on the network with the given
+// chain ID.
+func NewPartialTransaction(tx *Transaction, address *MultisigAddress,
+	chainID string) *PartialTransaction {
+
+	for _, input := range tx.Inputs {
+		input.UnlockScript = ""
+	}
+
+	return &PartialTransaction{
+		Tx:         tx,
+		Address:    address,
+		ChainID:    chainID,
+		Signatures: make(map[string]string),
+	}
+}
+
+// DecodePartialTransaction decodes a partially-signed transaction from its
+// encoded form.
+func DecodePartialTransaction(encoded string) (*PartialTransaction, error) {
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode base64: %v", err)
+	}
+
+	var p PartialTransaction
+	err = json.Unmarshal(buf, &p)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal: %v", err)
+	}
+
+	err = p.Verify()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid partial transaction: %v", err)
+	}
+
+	return &p, nil
+}
+
+// PartialTransaction is a transaction spending multisig outputs that is passed
+// between the participants until enough of them signed it. Signatures are
+// indexed by the hex-encoded public key. The signatures are only valid on the
+// network of ChainID.
+type PartialTransaction struct {
+	Tx         *Transaction
+	Address    *MultisigAddress
+	ChainID    string
+	Signatures map[string]string
+}
+
+// ID returns the signature hash of the transaction, which identifies a partial
+// transaction independently of its signatures.
+func (p PartialTransaction) ID() (Hash, error) {
+	return p.Tx.SigHash(p.ChainID)
+}
+
+// Encode returns the base64 representation of the partial transaction, which
+// can be copy-pasted between participants.
+func (p PartialTransaction) Encode() (string, error) {
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return "", xerrors.Errorf("failed to marshal: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Verify checks that the partial transaction is well-formed and that its
+// signatures are valid.
+func (p *PartialTransaction) Verify() error {
+	if p.Tx == nil || p.Address == nil {
+		return xerrors.Errorf("transaction or address missing")
+	}
+
+	address, err := NewMultisigAddress(p.Address.M, p.Address.PubKeys)
+	if err != nil {
+		return xerrors.Errorf("invalid address: %v", err)
+	}
+
+	p.Address = address
+
+	signatures := p.Signatures
+	p.Signatures = make(map[string]string)
+
+	for pubKey, sig := range signatures {
+		err = p.AddSignature(pubKey, sig)
+		if err != nil {
+			return xerrors.Errorf("invalid signature: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Sign adds the signature of the given hex-encoded private key
+func (p *PartialTransaction) Sign(privKey string) error {
+	buf, err := ParsePrivateKey(privKey)
+	if err != nil {
+		return xerrors.Errorf("invalid private key: %v", err)
+	}
+
+	pubKey := PublicKeyOf(buf)
+
+	sig, err := p.Tx.Sign(privKey, p.ChainID)
+	if err != nil {
+		return xerrors.Errorf("failed to sign: %v", err)
+	}
+
+	return p.AddSignature(pubKey, sig)
+}
+
+// AddSignature checks and adds a signature from one of the address' keys
+func (p *PartialTransaction) AddSignature(pubKey, sig string) error {
+	pubKey = strings.ToLower(pubKey)
+
+	if p.keyIndex(pubKey) < 0 {
+		return xerrors.Errorf("key %s is not part of %s", pubKey, p.Address)
+	}
+
+	vm := scriptVM{ctx: ScriptContext{Tx: p.Tx, ChainID: p.ChainID}}
+	ok, err := vm.checkSig(pubKey, sig)
+	if err != nil {
+		return xerrors.Errorf("failed to check signature: %v", err)
+	}
+
+	if !ok {
+		return xerrors.Errorf("wrong signature for key %s", pubKey)
+	}
+
+	p.Signatures[pubKey] = sig
+
+	return nil
+}
+
+// Merge adds the signatures of another partial transaction of the same
+// transaction.
+func (p *PartialTransaction) Merge(other *PartialTransaction) error {
+	id, err := p.ID()
+	if err != nil {
+		return xerrors.Errorf("failed to get id: %v", err)
+	}
+
+	otherID, err := other.ID()
+	if err != nil {
+		return xerrors.Errorf("failed to get other id: %v", err)
+	}
+
+	if id != otherID || p.Address.String() != other.Address.String() {
+		return xerrors.Errorf("partial transactions are different")
+	}
+
+	for pubKey, sig := range other.Signatures {
+		err = p.AddSignature(pubKey, sig)
+		if err != nil {
+			return xerrors.Errorf("failed to add signature: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// IsComplete returns true if the transaction has enough signatures
+func (p PartialTransaction) IsComplete() bool {
+	return len(p.Signatures) >= p.Address.M
+}
+
+// Finalize fills the unlocking scripts of the transaction with the signatures.
+func (p *PartialTransaction) Finalize() (*Transaction, error) {
+	if !p.IsComplete() {
+		return nil, xerrors.Errorf("not enough signatures: %d < %d",
+			len(p.Signatures), p.Address.M)
+	}
+
+	// signatures must be in the same order as the public keys
+	tokens := make([]string, 0, p.Address.M+1)
+	for _, pubKey := range p.Address.PubKeys {
+		sig, found := p.Signatures[pubKey]
+		if found && len(tokens) < p.Address.M {
+			tokens = append(tokens, "<"+sig+">")
+		}
+	}
+
+	unlock := Script(strings.Join(tokens, " "))
+
+	for _, input := range p.Tx.Inputs {
+		input.UnlockScript = unlock
+	}
+
+	return p.Tx, nil
+}
+
+// Status returns a human-readable signing status, such as "1/2 signatures".
+func (p PartialTransaction) Status() string {
+	return fmt.Sprintf("%d/%d signatures", len(p.Signatures), p.Address.M)
+}
+
+func (p PartialTransaction) keyIndex(pubKey string) int {
+	for i, key := range p.Address.PubKeys {
+		if key == pubKey {
+			return i
+		}
+	}
+
+	return -1
+}
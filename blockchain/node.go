@@ -1,6 +1,20 @@
 package blockchain
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// ChainIDHeader carries the chain ID of the node in the requests between
+	// nodes and in the responses.
+	ChainIDHeader = "X-Chain-Id"
+	// MagicHeader carries the network magic of the node, along with the chain
+	// ID.
+	MagicHeader = "X-Network-Magic"
+)
 
 // NewNode returns a new node
 func NewNode(host string, port int) *Node {
@@ -14,9 +28,45 @@ func NewNode(host string, port int) *Node {
 type Node struct {
 	Host string
 	Port int
+
+	// Version, Height and Features are learned during the handshake
+	Version  int      `json:",omitempty"`
+	Height   int      `json:",omitempty"`
+	Features []string `json:",omitempty"`
 }
 
 // GetHTTP returns the url as http://<host>:<port>
 func (n Node) GetHTTP() string {
 	return fmt.Sprintf("http://%s:%d", n.Host, n.Port)
 }
+
+// String returns the node as <host>:<port>
+func (n Node) String() string {
+	return fmt.Sprintf("%s:%d", n.Host, n.Port)
+}
+
+// SetNetwork sets the chain ID and the network magic of the node in the
+// headers of a request or a response.
+func (b *Blockchain) SetNetwork(header http.Header) {
+	header.Set(ChainIDHeader, b.Genesis.ChainID)
+	header.Set(MagicHeader, b.Genesis.Magic())
+}
+
+// CheckNetwork returns an error if the headers of a request or a response do
+// not come from a node of the same network.
+func (b *Blockchain) CheckNetwork(header http.Header) error {
+	chainID := header.Get(ChainIDHeader)
+	magic := header.Get(MagicHeader)
+
+	if magic == "" {
+		return xerrors.Errorf("no network magic")
+	}
+
+	if chainID != b.Genesis.ChainID || magic != b.Genesis.Magic() {
+		return xerrors.Errorf("other network: chain '%s' with magic %s instead "+
+			"of chain '%s' with magic %s", chainID, magic, b.Genesis.ChainID,
+			b.Genesis.Magic())
+	}
+
+	return nil
+}
@@ -0,0 +1,409 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// NewProofOfAuthority returns a new proof of authority consensus with the
+// initial set of validators, given as hex-encoded public keys. privKey is the
+// hex-encoded key of this node, which can be empty if the node is not a
+// validator.
+func NewProofOfAuthority(validators []string, privKey string) (*ProofOfAuthority, error) {
+	if len(validators) == 0 {
+		return nil, xerrors.Errorf("no validator")
+	}
+
+	initial := make([]string, len(validators))
+	for i, validator := range validators {
+		buf, err := hex.DecodeString(validator)
+		if err != nil || len(buf) != ed25519.PublicKeySize {
+			return nil, xerrors.Errorf("invalid validator key '%s'", validator)
+		}
+		initial[i] = strings.ToLower(validator)
+	}
+
+	poa := &ProofOfAuthority{
+		Validators: initial,
+	}
+
+	if privKey != "" {
+		key, err := ParsePrivateKey(privKey)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid validator private key: %v", err)
+		}
+		poa.privKey = key
+	}
+
+	return poa, nil
+}
+
+// ProofOfAuthority is the consensus where a set of validators take turns, in a
+// round-robin order, to sign blocks. The block at index i must be signed by
+// the validator i modulo the number of validators. Validators are added or
+// removed by a majority of votes from the current validators.
+//
+// The votes are cast for an epoch, which counts the changes of validators. A
+// vote only counts in its epoch, and once: it cannot be included again after
+// the validators changed. The votes are signed with the chain ID, and cannot
+// be replayed on another network.
+//
+// - implements Consensus
+// - implements TransactionChecker
+type ProofOfAuthority struct {
+	// Validators is the initial set of validators
+	Validators []string
+	// ChainID is the chain ID of the network, signed in the votes.
+	// NewBlockchain sets it to the chain ID of the genesis if it is empty.
+	ChainID string
+
+	privKey ed25519.PrivateKey
+}
+
+// Vote is a vote from a validator to add or remove a candidate, during an
+// epoch
+type Vote struct {
+	Validator string
+	Candidate string
+	Add       bool
+	Epoch     int
+	Signature string
+}
+
+// Hash returns the hash that is signed by the validator on the network with
+// the given chain ID. It is the hash of the chain ID followed by the vote
+// without its signature, as for the transactions.
+func (v Vote) Hash(chainID string) ([32]byte, error) {
+	v.Signature = ""
+
+	encodedVote, err := v.MarshalBinary()
+	if err != nil {
+		return [32]byte{}, xerrors.Errorf("failed to encode vote: %v", err)
+	}
+
+	e := &encoder{}
+	e.string(chainID)
+	e.Write(encodedVote)
+
+	return sha256.Sum256(e.Bytes()), nil
+}
+
+// Name implements Consensus
+func (p ProofOfAuthority) Name() string {
+	return "poa"
+}
+
+// PubKey returns the public key of this node, or an empty string if it is not
+// configured with a validator key.
+func (p ProofOfAuthority) PubKey() string {
+	if p.privKey == nil {
+		return ""
+	}
+
+	return PublicKeyOf(p.privKey)
+}
+
+// Seal implements Consensus. It signs the block if it is our turn.
+func (p ProofOfAuthority) Seal(chain []*Block, block *Block) error {
+	if p.privKey == nil {
+		return xerrors.Errorf("this node is not configured as a validator")
+	}
+
+	expected, err := p.ExpectedSigner(chain, block.Index)
+	if err != nil {
+		return xerrors.Errorf("failed to get expected signer: %v", err)
+	}
+
+	if expected != p.PubKey() {
+		return xerrors.Errorf("not our turn, block %d must be signed by %s",
+			block.Index, expected)
+	}
+
+	return block.Sign(p.privKey)
+}
+
+// Verify implements Consensus. It checks that the block is signed by the
+// validator whose turn it is and that the votes come from validators.
+func (p ProofOfAuthority) Verify(chain []*Block, block *Block) error {
+	state := p.stateAt(chain)
+	if len(state.validators) == 0 {
+		return xerrors.Errorf("no validator left")
+	}
+
+	expected := state.validators[block.Index%len(state.validators)]
+	if block.Signer != expected {
+		return xerrors.Errorf("block %d signed by %s instead of %s",
+			block.Index, block.Signer, expected)
+	}
+
+	err := block.VerifySignature()
+	if err != nil {
+		return xerrors.Errorf("invalid block signature: %v", err)
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.Vote == nil {
+			continue
+		}
+
+		err = state.check(tx.Vote)
+		if err != nil {
+			return xerrors.Errorf("invalid vote: %v", err)
+		}
+
+		state.apply(tx.Vote)
+	}
+
+	return nil
+}
+
+// CheckTransaction implements TransactionChecker. A vote must be valid for the
+// epoch reached after the chain and the previous transactions.
+func (p ProofOfAuthority) CheckTransaction(chain []*Block, previous []*Transaction,
+	tx *Transaction) error {
+
+	if tx.Vote == nil {
+		return nil
+	}
+
+	state := p.stateAt(chain)
+
+	for _, prev := range previous {
+		if prev.Vote != nil && state.check(prev.Vote) == nil {
+			state.apply(prev.Vote)
+		}
+	}
+
+	return state.check(tx.Vote)
+}
+
+// ForkChoice implements Consensus. Every block has the same weight, so the
+// longest chain wins.
+func (p ProofOfAuthority) ForkChoice(current, candidate []*Block) bool {
+	return len(candidate) > len(current)
+}
+
+// ExpectedSigner returns the validator that must sign the block at index
+// appended to the chain.
+func (p ProofOfAuthority) ExpectedSigner(chain []*Block, index int) (string, error) {
+	validators, err := p.ValidatorsAt(chain)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get validators: %v", err)
+	}
+
+	return validators[index%len(validators)], nil
+}
+
+// ValidatorsAt returns the set of validators for the next block of the chain.
+// It starts from the initial validators and applies the votes of the chain: a
+// candidate is added or removed as soon as more than half of the validators
+// voted for it.
+func (p ProofOfAuthority) ValidatorsAt(chain []*Block) ([]string, error) {
+	state := p.stateAt(chain)
+
+	if len(state.validators) == 0 {
+		return nil, xerrors.Errorf("no validator left")
+	}
+
+	return state.validators, nil
+}
+
+// EpochAt returns the epoch of the votes for the next block of the chain
+func (p ProofOfAuthority) EpochAt(chain []*Block) int {
+	return p.stateAt(chain).epoch
+}
+
+// stateAt replays the votes of the chain from the initial validators. The
+// chain is valid, but the votes are checked again so that the ones of an
+// invalid candidate chain are not counted.
+func (p ProofOfAuthority) stateAt(chain []*Block) *authorityState {
+	state := &authorityState{
+		chainID:    p.ChainID,
+		validators: append([]string{}, p.Validators...),
+		tallies:    make(map[string]map[string]bool),
+	}
+
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			if tx.Vote != nil && state.check(tx.Vote) == nil {
+				state.apply(tx.Vote)
+			}
+		}
+	}
+
+	return state
+}
+
+// authorityState is the state of the votes after some blocks
+type authorityState struct {
+	chainID    string
+	validators []string
+	epoch      int
+	// proposal -> validators who voted for it during the epoch
+	tallies map[string]map[string]bool
+}
+
+// check returns an error if the vote cannot be counted: it must be signed by
+// a validator, for the current epoch, and not counted yet.
+func (s *authorityState) check(vote *Vote) error {
+	err := verifyVote(s.chainID, s.validators, vote)
+	if err != nil {
+		return err
+	}
+
+	if vote.Epoch != s.epoch {
+		return xerrors.Errorf("vote for epoch %d during epoch %d", vote.Epoch,
+			s.epoch)
+	}
+
+	if s.tallies[vote.key()][vote.Validator] {
+		return xerrors.Errorf("vote of %s already counted", vote.Validator)
+	}
+
+	return nil
+}
+
+// apply counts a checked vote. When more than half of the validators voted
+// for the proposal, it is applied and a new epoch starts, without any vote.
+func (s *authorityState) apply(vote *Vote) {
+	proposal := vote.key()
+
+	if s.tallies[proposal] == nil {
+		s.tallies[proposal] = make(map[string]bool)
+	}
+	s.tallies[proposal][vote.Validator] = true
+
+	if len(s.tallies[proposal])*2 <= len(s.validators) {
+		return
+	}
+
+	s.validators = applyVote(s.validators, vote)
+	s.epoch++
+	s.tallies = make(map[string]map[string]bool)
+}
+
+// NewVote returns a transaction containing a vote of this node to add or
+// remove a candidate, for the epoch of the next block of the chain.
+func (p ProofOfAuthority) NewVote(chain []*Block, candidate string, add bool) (*Transaction, error) {
+	if p.privKey == nil {
+		return nil, xerrors.Errorf("this node is not configured as a validator")
+	}
+
+	buf, err := hex.DecodeString(candidate)
+	if err != nil || len(buf) != ed25519.PublicKeySize {
+		return nil, xerrors.Errorf("invalid candidate key '%s'", candidate)
+	}
+
+	vote := &Vote{
+		Validator: p.PubKey(),
+		Candidate: strings.ToLower(candidate),
+		Add:       add,
+		Epoch:     p.EpochAt(chain),
+	}
+
+	h, err := vote.Hash(p.ChainID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to hash vote: %v", err)
+	}
+
+	vote.Signature = hex.EncodeToString(ed25519.Sign(p.privKey, h[:]))
+
+	tx := NewTransaction(vote.Validator, vote.Candidate, 0)
+	tx.Vote = vote
+
+	return tx, nil
+}
+
+func (v Vote) proposal() string {
+	if v.Add {
+		return "add"
+	}
+	return "remove"
+}
+
+// key identifies the proposal of the vote in the tallies
+func (v Vote) key() string {
+	return v.Candidate + "-" + v.proposal()
+}
+
+// verifyVote checks the keys and the signature of a vote. The keys come from
+// the other nodes: a key of the wrong size would make ed25519 panic, and a
+// candidate with an invalid key could never sign a block.
+func verifyVote(chainID string, validators []string, vote *Vote) error {
+	if indexOf(validators, vote.Validator) < 0 {
+		return xerrors.Errorf("%s is not a validator", vote.Validator)
+	}
+
+	pubKey, err := parseValidatorKey(vote.Validator)
+	if err != nil {
+		return xerrors.Errorf("invalid validator key: %v", err)
+	}
+
+	_, err = parseValidatorKey(vote.Candidate)
+	if err != nil {
+		return xerrors.Errorf("invalid candidate key: %v", err)
+	}
+
+	sig, err := hex.DecodeString(vote.Signature)
+	if err != nil {
+		return xerrors.Errorf("invalid signature: %v", err)
+	}
+
+	h, err := vote.Hash(chainID)
+	if err != nil {
+		return xerrors.Errorf("failed to hash vote: %v", err)
+	}
+
+	if !ed25519.Verify(pubKey, h[:], sig) {
+		return xerrors.Errorf("wrong signature from %s", vote.Validator)
+	}
+
+	return nil
+}
+
+// parseValidatorKey decodes the public key of a validator, which must be in
+// lowercase hex so that a validator has a single key
+func parseValidatorKey(key string) (ed25519.PublicKey, error) {
+	buf, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode '%s': %v", key, err)
+	}
+
+	if len(buf) != ed25519.PublicKeySize {
+		return nil, xerrors.Errorf("'%s' has %d bytes instead of %d", key,
+			len(buf), ed25519.PublicKeySize)
+	}
+
+	if hex.EncodeToString(buf) != key {
+		return nil, xerrors.Errorf("'%s' is not in lowercase", key)
+	}
+
+	return ed25519.PublicKey(buf), nil
+}
+
+func applyVote(validators []string, vote *Vote) []string {
+	i := indexOf(validators, vote.Candidate)
+
+	switch {
+	case vote.Add && i < 0:
+		return append(validators, vote.Candidate)
+	case !vote.Add && i >= 0 && len(validators) > 1:
+		return append(validators[:i], validators[i+1:]...)
+	default:
+		return validators
+	}
+}
+
+func indexOf(list []string, element string) int {
+	for i, e := range list {
+		if e == element {
+			return i
+		}
+	}
+
+	return -1
+}
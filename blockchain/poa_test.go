@@ -0,0 +1,162 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func newTestPoA(t *testing.T, validators ...testKey) (*Blockchain, *ProofOfAuthority) {
+	t.Helper()
+
+	genesis := DefaultGenesis()
+	genesis.ChainID = testChainID
+	genesis.Consensus = "poa"
+
+	for _, validator := range validators {
+		genesis.Validators = append(genesis.Validators, validator.pub)
+	}
+
+	consensus, err := genesis.NewConsensus(validators[0].priv)
+	if err != nil {
+		t.Fatalf("failed to create consensus: %v", err)
+	}
+
+	blockchain := NewBlockchain("node", genesis, consensus, nil, nil)
+
+	return blockchain, consensus.(*ProofOfAuthority)
+}
+
+// signedVote returns a transaction with a vote signed by the key, whatever
+// its candidate
+func signedVote(t *testing.T, key testKey, candidate string, epoch int) *Transaction {
+	t.Helper()
+
+	vote := &Vote{
+		Validator: key.pub,
+		Candidate: candidate,
+		Add:       true,
+		Epoch:     epoch,
+	}
+
+	h, err := vote.Hash(testChainID)
+	if err != nil {
+		t.Fatalf("failed to hash vote: %v", err)
+	}
+
+	privKey, err := ParsePrivateKey(key.priv)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+
+	vote.Signature = hex.EncodeToString(ed25519.Sign(privKey, h[:]))
+
+	tx := NewTransaction(vote.Validator, vote.Candidate, 0)
+	tx.Vote = vote
+
+	return tx
+}
+
+func TestVoteAddsValidator(t *testing.T) {
+	keys := newTestKeys(t, 2)
+	blockchain, poa := newTestPoA(t, keys[0])
+
+	tx, err := poa.NewVote(blockchain.Chain, keys[1].pub, true)
+	if err != nil {
+		t.Fatalf("failed to create vote: %v", err)
+	}
+
+	_, err = blockchain.AddTransaction(tx)
+	if err != nil {
+		t.Fatalf("failed to add vote: %v", err)
+	}
+
+	_, err = blockchain.MineBlock(context.Background(), "owner")
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+
+	validators, err := poa.ValidatorsAt(blockchain.Chain)
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+
+	if len(validators) != 2 || validators[1] != keys[1].pub {
+		t.Fatalf("validators are %v, expected the candidate to be added", validators)
+	}
+
+	if poa.EpochAt(blockchain.Chain) != 1 {
+		t.Fatalf("epoch is %d instead of 1", poa.EpochAt(blockchain.Chain))
+	}
+
+	// the vote is counted once, even if included again
+	_, err = blockchain.AddTransaction(tx)
+	if err == nil {
+		t.Fatal("vote of a previous epoch added")
+	}
+}
+
+func TestMalformedVotes(t *testing.T) {
+	keys := newTestKeys(t, 2)
+	blockchain, poa := newTestPoA(t, keys[0])
+
+	votes := map[string]*Transaction{
+		"short candidate":     signedVote(t, keys[0], "0a", 0),
+		"long candidate":      signedVote(t, keys[0], keys[1].pub+"00", 0),
+		"uppercase candidate": signedVote(t, keys[0], strings.ToUpper(keys[1].pub), 0),
+		"not hex candidate":   signedVote(t, keys[0], "zz", 0),
+	}
+
+	for name, tx := range votes {
+		_, err := blockchain.AddTransaction(tx)
+		if err == nil || !strings.Contains(err.Error(), "invalid candidate key") {
+			t.Errorf("%s: vote added, or wrong error: %v", name, err)
+		}
+
+		// a block of another node with the vote is refused, without a panic
+		block := &Block{
+			Index:        len(blockchain.Chain),
+			Timestamp:    blockchain.Genesis.Timestamp.Unix() + 1,
+			PrevHash:     blockchain.Genesis.BlockHash(),
+			Transactions: []*Transaction{tx},
+		}
+
+		privKey, err := ParsePrivateKey(keys[0].priv)
+		if err != nil {
+			t.Fatalf("failed to parse key: %v", err)
+		}
+
+		err = block.Sign(privKey)
+		if err != nil {
+			t.Fatalf("failed to sign block: %v", err)
+		}
+
+		err = poa.Verify(blockchain.Chain, block)
+		if err == nil || !strings.Contains(err.Error(), "invalid candidate key") {
+			t.Errorf("%s: block accepted, or wrong error: %v", name, err)
+		}
+	}
+
+	validators, err := poa.ValidatorsAt(blockchain.Chain)
+	if err != nil || len(validators) != 1 {
+		t.Fatalf("validators changed: %v, %v", validators, err)
+	}
+}
+
+func TestMalformedValidatorKey(t *testing.T) {
+	keys := newTestKeys(t, 2)
+
+	// the initial validators are checked by NewProofOfAuthority, but not when
+	// the consensus is built by hand
+	poa := ProofOfAuthority{Validators: []string{"0a"}, ChainID: testChainID}
+
+	tx := signedVote(t, keys[0], keys[1].pub, 0)
+	tx.Vote.Validator = "0a"
+
+	err := poa.CheckTransaction(nil, nil, tx)
+	if err == nil || !strings.Contains(err.Error(), "invalid validator key") {
+		t.Fatalf("vote accepted, or wrong error: %v", err)
+	}
+}
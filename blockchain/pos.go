@@ -0,0 +1,408 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// NewProofOfStake returns a new proof of stake consensus. initialStakes gives
+// the stake of the first stakeholders, indexed by their hex-encoded public
+// key, which is added to their balance on the chain. privKey is the
+// hex-encoded key of this node, which can be empty if the node does not forge
+// blocks.
+func NewProofOfStake(initialStakes map[string]int, slotDuration time.Duration,
+	privKey string) (*ProofOfStake, error) {
+
+	if slotDuration < time.Second {
+		return nil, xerrors.Errorf("slot duration must be at least 1s: %s",
+			slotDuration)
+	}
+
+	stakes := make(map[string]int, len(initialStakes))
+	for staker, stake := range initialStakes {
+		buf, err := hex.DecodeString(staker)
+		if err != nil || len(buf) != ed25519.PublicKeySize {
+			return nil, xerrors.Errorf("invalid staker key '%s'", staker)
+		}
+		stakes[strings.ToLower(staker)] = stake
+	}
+
+	pos := &ProofOfStake{
+		InitialStakes: stakes,
+		SlotDuration:  slotDuration,
+	}
+
+	if privKey != "" {
+		key, err := ParsePrivateKey(privKey)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid staker private key: %v", err)
+		}
+		pos.privKey = key
+	}
+
+	return pos, nil
+}
+
+// ProofOfStake is the consensus where time is divided in slots and the leader
+// of each slot, who is the only one allowed to forge a block, is drawn at
+// random with a probability proportional to its stake. The stake of a public
+// key is its initial stake plus its unspent outputs locked to its key hash:
+// only the stakeholder can move its stake, by signing the inputs spending
+// them. The transfers from and to the account do not change the stake, as
+// nothing proves that the stakeholder made them.
+//
+// The randomness of a slot is derived from the hash and the signature of the
+// previous block: nobody can predict it before that block is signed, and
+// everybody can verify it afterwards. The slot of a block is stored in its
+// Proof field.
+//
+// The blocks of a slot that has not started yet, according to the clock of the
+// node, are refused: a stakeholder cannot sign the blocks of its future slots
+// in advance to outrun the other stakeholders.
+//
+// A stakeholder that signs two different blocks for the same slot can be
+// slashed by anyone submitting both blocks as evidence in a transaction. A
+// slashed stakeholder loses its stake.
+//
+// - implements Consensus
+// - implements EvidenceCollector
+type ProofOfStake struct {
+	InitialStakes map[string]int
+	SlotDuration  time.Duration
+
+	// Clock gives the current slot. NewBlockchain sets it to the clock of the
+	// node if it is nil.
+	Clock Clock
+	// ChainID is the chain ID of the network, signed in the inputs spending
+	// the stake. NewBlockchain sets it to the chain ID of the genesis if it is
+	// empty.
+	ChainID string
+
+	privKey ed25519.PrivateKey
+}
+
+// maxSlotDrift is the number of slots a block can be ahead of the clock of the
+// node, as the clocks of the nodes are not exactly in sync
+const maxSlotDrift = 1
+
+// SlashingEvidence holds two different blocks signed by the same stakeholder
+// for the same slot.
+type SlashingEvidence struct {
+	BlockA *Block
+	BlockB *Block
+}
+
+// Name implements Consensus
+func (p ProofOfStake) Name() string {
+	return "pos"
+}
+
+// PubKey returns the public key of this node, or an empty string if it is not
+// configured with a staker key.
+func (p ProofOfStake) PubKey() string {
+	if p.privKey == nil {
+		return ""
+	}
+
+	return PublicKeyOf(p.privKey)
+}
+
+// SlotAt returns the slot of a Unix time in nanoseconds, as used in block
+// timestamps.
+func (p ProofOfStake) SlotAt(timestamp int64) int {
+	return int(timestamp / int64(p.SlotDuration))
+}
+
+// Seal implements Consensus. It signs the block if we are the leader of the
+// current slot.
+func (p ProofOfStake) Seal(chain []*Block, block *Block) error {
+	if p.privKey == nil {
+		return xerrors.Errorf("this node is not configured as a staker")
+	}
+
+	prev := chain[len(chain)-1]
+	slot := p.SlotAt(block.Timestamp)
+
+	if slot <= prev.Proof {
+		return xerrors.Errorf("slot %d already has a block, wait for the "+
+			"next slot", slot)
+	}
+
+	leader, err := p.LeaderAt(chain, slot)
+	if err != nil {
+		return xerrors.Errorf("failed to get leader: %v", err)
+	}
+
+	if leader != p.PubKey() {
+		return xerrors.Errorf("not our slot, slot %d leader is %s", slot, leader)
+	}
+
+	block.Proof = slot
+
+	return block.Sign(p.privKey)
+}
+
+// Verify implements Consensus. It checks that the block is signed by the
+// leader of its slot and that the slashing evidences are valid.
+func (p ProofOfStake) Verify(chain []*Block, block *Block) error {
+	prev := chain[len(chain)-1]
+	slot := block.Proof
+
+	if slot <= prev.Proof {
+		return xerrors.Errorf("slot %d is not after the previous one", slot)
+	}
+
+	current := p.SlotAt(p.now().UnixNano())
+	if slot > current+maxSlotDrift {
+		return xerrors.Errorf("slot %d has not started, current is %d", slot,
+			current)
+	}
+
+	if p.SlotAt(block.Timestamp) != slot {
+		return xerrors.Errorf("timestamp of block %d is not in slot %d",
+			block.Index, slot)
+	}
+
+	leader, err := p.LeaderAt(chain, slot)
+	if err != nil {
+		return xerrors.Errorf("failed to get leader: %v", err)
+	}
+
+	if block.Signer != leader {
+		return xerrors.Errorf("block %d signed by %s instead of %s",
+			block.Index, block.Signer, leader)
+	}
+
+	err = block.VerifySignature()
+	if err != nil {
+		return xerrors.Errorf("invalid block signature: %v", err)
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.Evidence == nil {
+			continue
+		}
+
+		err = tx.Evidence.Verify()
+		if err != nil {
+			return xerrors.Errorf("invalid evidence: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// now returns the time of the clock, or of the system if there is none
+func (p ProofOfStake) now() time.Time {
+	if p.Clock == nil {
+		return time.Now()
+	}
+
+	return p.Clock.Now()
+}
+
+// ForkChoice implements Consensus. The longest chain wins.
+func (p ProofOfStake) ForkChoice(current, candidate []*Block) bool {
+	return len(candidate) > len(current)
+}
+
+// StakesAt returns the stake of every stakeholder for the next block of the
+// chain. Slashed stakeholders have no stake.
+func (p ProofOfStake) StakesAt(chain []*Block) map[string]int {
+	stakes := make(map[string]int)
+
+	for staker, stake := range p.InitialStakes {
+		stakes[staker] = stake
+	}
+
+	for _, copies := range outputsOf(chain, p.ChainID) {
+		for _, tx := range copies {
+			if isStakeOutput(tx) {
+				stakes[tx.Receiver] += tx.Amount
+			}
+		}
+	}
+
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			if tx.Evidence != nil && tx.Evidence.BlockA != nil {
+				stakes[tx.Evidence.BlockA.Signer] = 0
+			}
+		}
+	}
+
+	for staker, stake := range stakes {
+		if stake <= 0 {
+			delete(stakes, staker)
+		}
+	}
+
+	return stakes
+}
+
+// LeaderAt returns the stakeholder allowed to forge the block of the given
+// slot after the chain. The leader is drawn with a probability proportional to
+// its stake, using the randomness of the last block of the chain.
+func (p ProofOfStake) LeaderAt(chain []*Block, slot int) (string, error) {
+	stakes := p.StakesAt(chain)
+
+	stakers := make([]string, 0, len(stakes))
+	total := 0
+
+	for staker, stake := range stakes {
+		stakers = append(stakers, staker)
+		total += stake
+	}
+
+	if total == 0 {
+		return "", xerrors.Errorf("nobody has stake")
+	}
+
+	// the map order is random, we need the same order on every node
+	sort.Strings(stakers)
+
+	seed, err := p.seed(chain[len(chain)-1], slot)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get seed: %v", err)
+	}
+
+	draw := int(binary.BigEndian.Uint64(seed[:8]) % uint64(total))
+
+	for _, staker := range stakers {
+		draw -= stakes[staker]
+		if draw < 0 {
+			return staker, nil
+		}
+	}
+
+	return stakers[len(stakers)-1], nil
+}
+
+// NewSlashingTransaction returns a transaction holding the evidence that a
+// stakeholder double-signed.
+func NewSlashingTransaction(evidence *SlashingEvidence, reporter string) (*Transaction, error) {
+	err := evidence.Verify()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid evidence: %v", err)
+	}
+
+	tx := NewTransaction(reporter, evidence.BlockA.Signer, 0)
+	tx.Evidence = evidence
+
+	return tx, nil
+}
+
+// CollectEvidence implements EvidenceCollector. It returns the slashing
+// evidences found by comparing two chains: blocks of the same slot signed by
+// the same stakeholder but with different content.
+func (p ProofOfStake) CollectEvidence(current, candidate []*Block) []*SlashingEvidence {
+	bySlot := make(map[int]*Block)
+	for _, block := range current[1:] {
+		bySlot[block.Proof] = block
+	}
+
+	evidences := make([]*SlashingEvidence, 0)
+
+	for _, block := range candidate[1:] {
+		other, found := bySlot[block.Proof]
+		if !found || other.Signer != block.Signer {
+			continue
+		}
+
+		evidence := &SlashingEvidence{BlockA: other, BlockB: block}
+		if evidence.Verify() == nil {
+			evidences = append(evidences, evidence)
+		}
+	}
+
+	return evidences
+}
+
+// Verify checks that the two blocks are different, for the same slot, and
+// signed by the same stakeholder.
+func (e SlashingEvidence) Verify() error {
+	if e.BlockA == nil || e.BlockB == nil {
+		return xerrors.Errorf("missing block")
+	}
+
+	if e.BlockA.Signer != e.BlockB.Signer {
+		return xerrors.Errorf("blocks have different signers")
+	}
+
+	if e.BlockA.Proof != e.BlockB.Proof {
+		return xerrors.Errorf("blocks are for different slots")
+	}
+
+	hashA, err := e.BlockA.SealHash()
+	if err != nil {
+		return xerrors.Errorf("failed to hash block A: %v", err)
+	}
+
+	hashB, err := e.BlockB.SealHash()
+	if err != nil {
+		return xerrors.Errorf("failed to hash block B: %v", err)
+	}
+
+	if hashA == hashB {
+		return xerrors.Errorf("blocks are the same")
+	}
+
+	err = e.BlockA.VerifySignature()
+	if err != nil {
+		return xerrors.Errorf("invalid signature of block A: %v", err)
+	}
+
+	err = e.BlockB.VerifySignature()
+	if err != nil {
+		return xerrors.Errorf("invalid signature of block B: %v", err)
+	}
+
+	return nil
+}
+
+// seed derives the randomness of a slot from the hash and the signature of the
+// previous block.
+func (p ProofOfStake) seed(prev *Block, slot int) ([32]byte, error) {
+	prevHash, err := prev.Hash()
+	if err != nil {
+		return [32]byte{}, xerrors.Errorf("failed to get hash: %v", err)
+	}
+
+	h := sha256.New()
+	h.Write(prevHash[:])
+	h.Write([]byte(prev.Signature))
+	binary.Write(h, binary.BigEndian, int64(slot))
+
+	var seed [32]byte
+	copy(seed[:], h.Sum(nil))
+
+	return seed, nil
+}
+
+// isStakeOutput tells if the output of a transaction is a stake: a positive
+// amount sent to a public key, and locked to its hash
+func isStakeOutput(tx *Transaction) bool {
+	if tx.Amount <= 0 || !isPubKey(tx.Receiver) {
+		return false
+	}
+
+	pubKeyHash, err := PubKeyHash(tx.Receiver)
+	if err != nil {
+		return false
+	}
+
+	return tx.LockScript == NewP2PKHScript(pubKeyHash)
+}
+
+func isPubKey(account string) bool {
+	buf, err := hex.DecodeString(account)
+	return err == nil && len(buf) == ed25519.PublicKeySize &&
+		hex.EncodeToString(buf) == account
+}
@@ -0,0 +1,213 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPoS(t *testing.T, stakes map[string]int) *ProofOfStake {
+	t.Helper()
+
+	pos, err := NewProofOfStake(stakes, time.Second, "")
+	if err != nil {
+		t.Fatalf("failed to create consensus: %v", err)
+	}
+
+	pos.ChainID = testChainID
+
+	return pos
+}
+
+// testChain returns a chain of unsealed blocks, one per list of transactions,
+// after a genesis block giving 100 to alice
+func testChain(blocks ...[]*Transaction) []*Block {
+	genesis := DefaultGenesis()
+	genesis.Alloc = map[string]int{"alice": 100}
+
+	chain := []*Block{genesis.Block()}
+
+	for i, txs := range blocks {
+		prevHash, _ := chain[i].Hash()
+		chain = append(chain, NewBlock(i+1, genesis.Timestamp.Add(time.Duration(i+1)*time.Second),
+			i+1, prevHash, txs))
+	}
+
+	return chain
+}
+
+func stakeOutput(t *testing.T, sender string, key testKey, amount int) *Transaction {
+	t.Helper()
+
+	tx := NewTransaction(sender, key.pub, amount)
+	tx.LockScript = NewP2PKHScript(key.hash)
+
+	return tx
+}
+
+// spend returns a transaction spending the output of prev, signed by key
+func spend(t *testing.T, prev *Transaction, key testKey, receiver string) *Transaction {
+	t.Helper()
+
+	id, err := prev.ID()
+	if err != nil {
+		t.Fatalf("failed to get id: %v", err)
+	}
+
+	tx := NewTransaction(prev.Receiver, receiver, prev.Amount)
+	tx.Inputs = []*Input{{PrevTx: id}}
+
+	tx.Inputs[0].UnlockScript = Script("<" + sign(t, tx, key, testChainID) +
+		"> <" + key.pub + ">")
+
+	return tx
+}
+
+func TestLeaderAt(t *testing.T) {
+	keys := newTestKeys(t, 2)
+	chain := testChain()
+
+	single := newTestPoS(t, map[string]int{keys[0].pub: 5})
+
+	for slot := 1; slot < 20; slot++ {
+		leader, err := single.LeaderAt(chain, slot)
+		if err != nil || leader != keys[0].pub {
+			t.Fatalf("slot %d: leader %s (%v) instead of the only stakeholder",
+				slot, leader, err)
+		}
+	}
+
+	pos := newTestPoS(t, map[string]int{keys[0].pub: 300, keys[1].pub: 100})
+
+	const slots = 4000
+	won := 0
+
+	for slot := 1; slot <= slots; slot++ {
+		leader, err := pos.LeaderAt(chain, slot)
+		if err != nil {
+			t.Fatalf("failed to get leader: %v", err)
+		}
+
+		again, _ := pos.LeaderAt(chain, slot)
+		if again != leader {
+			t.Fatalf("slot %d: leader %s then %s", slot, leader, again)
+		}
+
+		if leader == keys[0].pub {
+			won++
+		}
+	}
+
+	// the first stakeholder has 3/4 of the stake
+	share := float64(won) / slots
+	if share < 0.70 || share > 0.80 {
+		t.Fatalf("the stakeholder with 75%% of the stake led %.1f%% of the slots",
+			share*100)
+	}
+
+	nobody := newTestPoS(t, map[string]int{})
+
+	_, err := nobody.LeaderAt(chain, 1)
+	if err == nil {
+		t.Fatal("leader drawn without stake")
+	}
+}
+
+func TestStakesAt(t *testing.T) {
+	keys := newTestKeys(t, 3)
+	staker, other, outsider := keys[0], keys[1], keys[2]
+
+	pos := newTestPoS(t, map[string]int{staker.pub: 10, other.pub: 10})
+
+	received := stakeOutput(t, "alice", other, 5)
+
+	forged := spend(t, received, outsider, "mallory")
+	forged.Sender = "mallory"
+
+	slashed := NewTransaction("reporter", staker.pub, 0)
+	slashed.Evidence = &SlashingEvidence{BlockA: &Block{Signer: staker.pub},
+		BlockB: &Block{Signer: staker.pub}}
+
+	tests := []struct {
+		name   string
+		blocks [][]*Transaction
+		stakes map[string]int
+	}{
+		{"initial", nil, map[string]int{staker.pub: 10, other.pub: 10}},
+		{"transfer from the stakeholder",
+			[][]*Transaction{{NewTransaction(staker.pub, "mallory", 1000000000)}},
+			map[string]int{staker.pub: 10, other.pub: 10}},
+		{"negative transfer to the stakeholder",
+			[][]*Transaction{{NewTransaction("mallory", staker.pub, -1000000000)}},
+			map[string]int{staker.pub: 10, other.pub: 10}},
+		{"unlocked transfer to the stakeholder",
+			[][]*Transaction{{NewTransaction("alice", staker.pub, 50)}},
+			map[string]int{staker.pub: 10, other.pub: 10}},
+		{"output locked to the stakeholder",
+			[][]*Transaction{{received}},
+			map[string]int{staker.pub: 10, other.pub: 15}},
+		{"output locked to a new stakeholder",
+			[][]*Transaction{{stakeOutput(t, "alice", outsider, 7)}},
+			map[string]int{staker.pub: 10, other.pub: 10, outsider.pub: 7}},
+		{"output spent by the stakeholder",
+			[][]*Transaction{{received}, {spend(t, received, other, "bob")}},
+			map[string]int{staker.pub: 10, other.pub: 10}},
+		{"output spent with another key",
+			[][]*Transaction{{received}, {forged}},
+			map[string]int{staker.pub: 10, other.pub: 15}},
+		{"slashed", [][]*Transaction{{received}, {slashed}},
+			map[string]int{other.pub: 15}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stakes := pos.StakesAt(testChain(test.blocks...))
+
+			if len(stakes) != len(test.stakes) {
+				t.Fatalf("stakes are %v instead of %v", stakes, test.stakes)
+			}
+
+			for staker, stake := range test.stakes {
+				if stakes[staker] != stake {
+					t.Fatalf("stakes are %v instead of %v", stakes, test.stakes)
+				}
+			}
+		})
+	}
+}
+
+func TestAddTransactionChecksAmount(t *testing.T) {
+	keys := newTestKeys(t, 1)
+
+	genesis := DefaultGenesis()
+	genesis.Alloc = map[string]int{"alice": 100}
+
+	blockchain := NewBlockchain("node", genesis, NewProofOfWork("00"), nil, nil)
+
+	tests := []struct {
+		tx  *Transaction
+		err string
+	}{
+		{NewTransaction("alice", "bob", -5), "amount must be positive"},
+		{NewTransaction("alice", "bob", 0), "amount must be positive"},
+		{NewTransaction(keys[0].pub, "mallory", 10), "cannot send 10"},
+		{NewTransaction("alice", "bob", 101), "cannot send 101"},
+		{NewTransaction("alice", "bob", 60), ""},
+		// the pending transactions are counted
+		{NewTransaction("alice", "carol", 60), "alice has 40, cannot send 60"},
+		{NewTransaction("bob", "carol", 60), ""},
+	}
+
+	for _, test := range tests {
+		_, err := blockchain.AddTransaction(test.tx)
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Fatalf("%s sending %d: unexpected error: %v", test.tx.Sender,
+				test.tx.Amount, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Fatalf("%s sending %d: error '%v' instead of '%s'", test.tx.Sender,
+				test.tx.Amount, err, test.err)
+		}
+	}
+}
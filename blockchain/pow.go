@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// NewProofOfWork returns a new proof of work consensus. The difficulty is the
+// prefix that the hash of a proof must have.
+func NewProofOfWork(difficulty string) *ProofOfWork {
+	return &ProofOfWork{
+		Difficulty: difficulty,
+		stats:      &miningStats{},
+	}
+}
+
+// ProofOfWork is the Bitcoin-like consensus where the right to append a block
+// is earned by finding a proof, ie. a nounce, whose hash has a given prefix.
+// The longest chain wins.
+//
+// - implements Consensus
+type ProofOfWork struct {
+	Difficulty string
+
+	stats *miningStats
+}
+
+// miningStats counts the hashes computed to seal blocks
+type miningStats struct {
+	sync.Mutex
+	hashes uint64
+	rate   float64
+}
+
+// Name implements Consensus
+func (p ProofOfWork) Name() string {
+	return "pow"
+}
+
+// Seal implements Consensus. It calculates the right nounce, ie. the proof.
+func (p ProofOfWork) Seal(chain []*Block, block *Block) error {
+	prev := chain[len(chain)-1]
+	newProof := 0
+	start := time.Now()
+
+	for !p.isValidProof(prev.Proof, newProof) {
+		newProof++
+	}
+
+	block.Proof = newProof
+
+	if p.stats != nil {
+		p.stats.Lock()
+		p.stats.hashes += uint64(newProof + 1)
+		p.stats.rate = float64(newProof+1) / time.Since(start).Seconds()
+		p.stats.Unlock()
+	}
+
+	return nil
+}
+
+// HashStats returns the number of hashes computed to seal blocks, and the hash
+// rate, in hashes per second, of the last sealed block.
+func (p ProofOfWork) HashStats() (uint64, float64) {
+	if p.stats == nil {
+		return 0, 0
+	}
+
+	p.stats.Lock()
+	defer p.stats.Unlock()
+
+	return p.stats.hashes, p.stats.rate
+}
+
+// Verify implements Consensus. We apply the same hash operation as in the
+// Seal function and check if it returns a correct hash.
+func (p ProofOfWork) Verify(chain []*Block, block *Block) error {
+	prev := chain[len(chain)-1]
+	if !p.isValidProof(prev.Proof, block.Proof) {
+		return xerrors.Errorf("invalid proof %d for block %d", block.Proof,
+			block.Index)
+	}
+
+	return nil
+}
+
+// ForkChoice implements Consensus. As every block has the same difficulty, the
+// longest chain is the one with the most work.
+func (p ProofOfWork) ForkChoice(current, candidate []*Block) bool {
+	return len(candidate) > len(current)
+}
+
+// Work returns the expected number of hashes computed to mine the blocks of
+// the chain, after the genesis block.
+func (p ProofOfWork) Work(chain []*Block) float64 {
+	if len(chain) < 2 {
+		return 0
+	}
+
+	return float64(len(chain)-1) * math.Pow(16, float64(len(p.Difficulty)))
+}
+
+func (p ProofOfWork) isValidProof(prevProof, proof int) bool {
+	hashOperation := sha256.Sum256([]byte(fmt.Sprintf("%d",
+		proof*proof-prevProof*prevProof)))
+
+	return strings.HasPrefix(hex.EncodeToString(hashOperation[:]), p.Difficulty)
+}
@@ -0,0 +1,349 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Script is a tiny stack-based program, inspired by the Bitcoin script
+// language, written as a list of space-separated tokens. A token is either an
+// opcode (DUP, HASH, EQUALVERIFY, CHECKSIG, CHECKMULTISIG,
+// CHECKLOCKTIMEVERIFY), some hex-encoded data between angle brackets (ie.
+// <0a1b>), or a decimal number. Data and numbers are pushed on the stack.
+//
+// A pay-to-public-key-hash output is locked with:
+//
+//	DUP HASH <pubkey hash> EQUALVERIFY CHECKSIG
+//
+// and unlocked with:
+//
+//	<signature> <pubkey>
+type Script string
+
+const (
+	// MaxScriptSteps is the maximum number of tokens that can be executed when
+	// evaluating an unlocking and a locking script.
+	MaxScriptSteps = 201
+
+	// MaxStackSize is the maximum number of elements on the stack.
+	MaxStackSize = 1000
+
+	// LockTimeThreshold is the limit below which a lock time is interpreted as
+	// a block height. Above, it is a Unix timestamp in seconds.
+	LockTimeThreshold = 500000000
+)
+
+const (
+	opDup                 = "DUP"
+	opHash                = "HASH"
+	opEqualVerify         = "EQUALVERIFY"
+	opCheckSig            = "CHECKSIG"
+	opCheckMultiSig       = "CHECKMULTISIG"
+	opCheckLockTimeVerify = "CHECKLOCKTIMEVERIFY"
+
+	stackTrue  = "1"
+	stackFalse = "0"
+)
+
+// NewP2PKHScript returns the script that locks an output to the owner of the
+// public key whose hash is given.
+func NewP2PKHScript(pubKeyHash string) Script {
+	return Script("DUP HASH <" + pubKeyHash + "> EQUALVERIFY CHECKSIG")
+}
+
+// Tokens returns the list of tokens of the script
+func (s Script) Tokens() []string {
+	return strings.Fields(string(s))
+}
+
+// ScriptContext holds the data needed by the opcodes that depend on the
+// transaction being validated and on the block including it.
+type ScriptContext struct {
+	// Tx is the transaction whose signature hash is checked by CHECKSIG and
+	// CHECKMULTISIG.
+	Tx *Transaction
+	// Height is the index of the block including the transaction
+	Height int
+	// Time is the Unix time, in seconds, of the block including the
+	// transaction.
+	Time int64
+	// ChainID is the chain ID of the network, which is signed along with the
+	// transaction.
+	ChainID string
+}
+
+// TraceStep describes the state of the stack after the execution of a token.
+type TraceStep struct {
+	Step  int
+	Token string
+	Stack []string
+	Error string
+}
+
+// ExecuteScripts runs the unlocking script followed by the locking script on
+// the same stack. It returns the trace of the execution, step by step, and an
+// error if the scripts do not successfully unlock the output.
+func ExecuteScripts(unlock, lock Script, ctx ScriptContext) ([]TraceStep, error) {
+	tokens := append(unlock.Tokens(), lock.Tokens()...)
+	if len(tokens) > MaxScriptSteps {
+		return nil, xerrors.Errorf("too many steps: %d > %d", len(tokens),
+			MaxScriptSteps)
+	}
+
+	vm := scriptVM{ctx: ctx}
+	trace := make([]TraceStep, 0, len(tokens))
+
+	for i, token := range tokens {
+		err := vm.step(token)
+
+		step := TraceStep{
+			Step:  i + 1,
+			Token: token,
+			Stack: append([]string{}, vm.stack...),
+		}
+
+		if err != nil {
+			step.Error = err.Error()
+			trace = append(trace, step)
+			return trace, xerrors.Errorf("step %d '%s' failed: %v", i+1, token, err)
+		}
+
+		trace = append(trace, step)
+	}
+
+	if len(vm.stack) == 0 {
+		return trace, xerrors.Errorf("stack is empty at the end of the execution")
+	}
+
+	if !isTrue(vm.stack[len(vm.stack)-1]) {
+		return trace, xerrors.Errorf("top of the stack is false")
+	}
+
+	return trace, nil
+}
+
+// scriptVM holds the stack of a script being executed.
+type scriptVM struct {
+	ctx   ScriptContext
+	stack []string
+}
+
+func (vm *scriptVM) step(token string) error {
+	switch token {
+	case opDup:
+		top, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.stack = append(vm.stack, top, top)
+
+	case opHash:
+		top, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		buf, err := hex.DecodeString(top)
+		if err != nil {
+			return xerrors.Errorf("failed to decode hex: %v", err)
+		}
+		h := sha256.Sum256(buf)
+		vm.stack = append(vm.stack, hex.EncodeToString(h[:]))
+
+	case opEqualVerify:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if a != b {
+			return xerrors.Errorf("'%s' != '%s'", a, b)
+		}
+
+	case opCheckSig:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		ok, err := vm.checkSig(pubKey, sig)
+		if err != nil {
+			return err
+		}
+		vm.pushBool(ok)
+
+	case opCheckMultiSig:
+		ok, err := vm.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(ok)
+
+	case opCheckLockTimeVerify:
+		lockTime, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		if lockTime < LockTimeThreshold {
+			if vm.ctx.Height < lockTime {
+				return xerrors.Errorf("locked until block %d, current is %d",
+					lockTime, vm.ctx.Height)
+			}
+		} else if vm.ctx.Time < int64(lockTime) {
+			return xerrors.Errorf("locked until time %d, current is %d",
+				lockTime, vm.ctx.Time)
+		}
+
+	default:
+		item, err := parseData(token)
+		if err != nil {
+			return err
+		}
+		vm.stack = append(vm.stack, item)
+	}
+
+	if len(vm.stack) > MaxStackSize {
+		return xerrors.Errorf("stack overflow")
+	}
+
+	return nil
+}
+
+// checkMultiSig pops the number of public keys, the public keys, the number of
+// signatures and the signatures. Signatures must be provided in the same order
+// as their public keys.
+func (vm *scriptVM) checkMultiSig() (bool, error) {
+	n, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > len(vm.stack) {
+		return false, xerrors.Errorf("invalid number of public keys: %d", n)
+	}
+
+	pubKeys := make([]string, n)
+	for i := n - 1; i >= 0; i-- {
+		pubKeys[i], _ = vm.pop()
+	}
+
+	m, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n || m > len(vm.stack) {
+		return false, xerrors.Errorf("invalid number of signatures: %d", m)
+	}
+
+	sigs := make([]string, m)
+	for i := m - 1; i >= 0; i-- {
+		sigs[i], _ = vm.pop()
+	}
+
+	k := 0
+	for _, sig := range sigs {
+		for ; k < len(pubKeys); k++ {
+			ok, err := vm.checkSig(pubKeys[k], sig)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				break
+			}
+		}
+		if k == len(pubKeys) {
+			return false, nil
+		}
+		k++
+	}
+
+	return true, nil
+}
+
+func (vm *scriptVM) checkSig(pubKeyHex, sigHex string) (bool, error) {
+	if vm.ctx.Tx == nil {
+		return false, xerrors.Errorf("no transaction to check the signature against")
+	}
+
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return false, xerrors.Errorf("invalid public key '%s'", pubKeyHex)
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false, xerrors.Errorf("invalid signature '%s'", sigHex)
+	}
+
+	sigHash, err := vm.ctx.Tx.SigHash(vm.ctx.ChainID)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get signature hash: %v", err)
+	}
+
+	return ed25519.Verify(pubKey, sigHash[:], sig), nil
+}
+
+func (vm *scriptVM) pop() (string, error) {
+	if len(vm.stack) == 0 {
+		return "", xerrors.Errorf("stack is empty")
+	}
+
+	top := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return top, nil
+}
+
+func (vm *scriptVM) popInt() (int, error) {
+	top, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(top)
+	if err != nil {
+		return 0, xerrors.Errorf("'%s' is not a number", top)
+	}
+
+	return n, nil
+}
+
+func (vm *scriptVM) pushBool(ok bool) {
+	if ok {
+		vm.stack = append(vm.stack, stackTrue)
+	} else {
+		vm.stack = append(vm.stack, stackFalse)
+	}
+}
+
+// parseData parses a data token, which is either <hex> or a decimal number.
+func parseData(token string) (string, error) {
+	if strings.HasPrefix(token, "<") && strings.HasSuffix(token, ">") {
+		data := strings.ToLower(token[1 : len(token)-1])
+		_, err := hex.DecodeString(data)
+		if err != nil {
+			return "", xerrors.Errorf("invalid hex data '%s': %v", token, err)
+		}
+		return data, nil
+	}
+
+	_, err := strconv.Atoi(token)
+	if err != nil {
+		return "", xerrors.Errorf("unknown token '%s'", token)
+	}
+
+	return token, nil
+}
+
+func isTrue(item string) bool {
+	return item != "" && item != stackFalse
+}
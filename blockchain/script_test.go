@@ -0,0 +1,273 @@
+package blockchain
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testChainID = "test"
+
+type testKey struct {
+	pub, priv, hash string
+}
+
+func newTestKeys(t *testing.T, n int) []testKey {
+	t.Helper()
+
+	entropy := NewSeededEntropy(1)
+	keys := make([]testKey, n)
+
+	for i := range keys {
+		pub, priv, err := NewKeyPair(entropy)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+
+		hash, err := PubKeyHash(pub)
+		if err != nil {
+			t.Fatalf("failed to hash key: %v", err)
+		}
+
+		keys[i] = testKey{pub: pub, priv: priv, hash: hash}
+	}
+
+	return keys
+}
+
+func sign(t *testing.T, tx *Transaction, key testKey, chainID string) string {
+	t.Helper()
+
+	sig, err := tx.Sign(key.priv, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	return sig
+}
+
+func TestExecuteScripts(t *testing.T) {
+	keys := newTestKeys(t, 3)
+	alice, bob, carol := keys[0], keys[1], keys[2]
+
+	tx := NewTransaction("alice", "bob", 5)
+	tx.Inputs = []*Input{{PrevTx: Hash{1}}}
+
+	aliceSig := sign(t, tx, alice, testChainID)
+	bobSig := sign(t, tx, bob, testChainID)
+	carolSig := sign(t, tx, carol, testChainID)
+	otherChainSig := sign(t, tx, alice, "other")
+
+	p2pkh := NewP2PKHScript(alice.hash)
+
+	msig, err := NewMultisigAddress(2, []string{alice.pub, bob.pub, carol.pub})
+	if err != nil {
+		t.Fatalf("failed to create multisig address: %v", err)
+	}
+
+	ctx := ScriptContext{Tx: tx, Height: 10, Time: 1600000000, ChainID: testChainID}
+
+	tests := []struct {
+		name   string
+		unlock string
+		lock   Script
+		ctx    ScriptContext
+		// err is a part of the expected error, empty if the unlock is valid
+		err string
+	}{
+		{"p2pkh", "<" + aliceSig + "> <" + alice.pub + ">", p2pkh, ctx, ""},
+		{"p2pkh other key", "<" + bobSig + "> <" + bob.pub + ">", p2pkh, ctx,
+			"EQUALVERIFY"},
+		{"p2pkh wrong signature", "<" + bobSig + "> <" + alice.pub + ">", p2pkh,
+			ctx, "top of the stack is false"},
+		{"p2pkh other chain", "<" + otherChainSig + "> <" + alice.pub + ">", p2pkh,
+			ctx, "top of the stack is false"},
+		{"p2pkh no signature", "<" + alice.pub + ">", p2pkh, ctx, "stack is empty"},
+		{"p2pkh no transaction", "<" + aliceSig + "> <" + alice.pub + ">", p2pkh,
+			ScriptContext{ChainID: testChainID}, "no transaction"},
+		{"dup empty stack", "", "DUP", ctx, "stack is empty"},
+		{"hash", "<00>", "HASH <6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d> EQUALVERIFY 1",
+			ctx, ""},
+		{"checksig invalid key", "<" + aliceSig + "> <0a>", "CHECKSIG", ctx,
+			"invalid public key"},
+		{"unknown token", "", "NOP", ctx, "unknown token"},
+		{"invalid hex", "", "<zz>", ctx, "invalid hex data"},
+		{"empty scripts", "", "", ctx, "stack is empty at the end"},
+		{"false", "", "0", ctx, "top of the stack is false"},
+		{"too many steps", strings.Repeat("1 ", MaxScriptSteps), "1", ctx,
+			"too many steps"},
+		{"max steps", strings.Repeat("1 ", MaxScriptSteps-1), "1", ctx, ""},
+
+		{"multisig in order", "<" + aliceSig + "> <" + carolSig + ">", msig.Script(),
+			ctx, ""},
+		{"multisig reversed", "<" + carolSig + "> <" + aliceSig + ">", msig.Script(),
+			ctx, "top of the stack is false"},
+		{"multisig same signature twice", "<" + aliceSig + "> <" + aliceSig + ">",
+			msig.Script(), ctx, "top of the stack is false"},
+		{"multisig one signature", "<" + bobSig + ">", msig.Script(), ctx,
+			"invalid number of signatures"},
+		{"multisig m greater than n", "<" + aliceSig + "> <" + bobSig + ">",
+			Script("3 <" + alice.pub + "> <" + bob.pub + "> 2 CHECKMULTISIG"), ctx,
+			"invalid number of signatures"},
+		{"multisig missing keys", "", "1 <" + Script(alice.pub) + "> 3 CHECKMULTISIG",
+			ctx, "invalid number of public keys"},
+
+		{"height locked", "", "10 CHECKLOCKTIMEVERIFY 1", ctx, ""},
+		{"height not reached", "", "11 CHECKLOCKTIMEVERIFY 1", ctx,
+			"locked until block 11"},
+		{"time locked", "", Script(strconv.Itoa(1600000000) + " CHECKLOCKTIMEVERIFY 1"),
+			ctx, ""},
+		{"time not reached", "", Script(strconv.Itoa(1600000001) + " CHECKLOCKTIMEVERIFY 1"),
+			ctx, "locked until time 1600000001"},
+		{"time threshold", "", Script(strconv.Itoa(LockTimeThreshold) + " CHECKLOCKTIMEVERIFY 1"),
+			ScriptContext{Height: LockTimeThreshold, Time: LockTimeThreshold - 1},
+			"locked until time"},
+		{"lock time not a number", "<0a>", "CHECKLOCKTIMEVERIFY 1", ctx,
+			"is not a number"},
+		{"lock time empty stack", "", "CHECKLOCKTIMEVERIFY", ctx, "stack is empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ExecuteScripts(Script(test.unlock), test.lock, test.ctx)
+
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case test.err != "" && err == nil:
+				t.Fatalf("unlocked, expected '%s'", test.err)
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Fatalf("error '%v' does not contain '%s'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestExecuteScriptsTrace(t *testing.T) {
+	trace, err := ExecuteScripts("1", "DUP EQUALVERIFY DUP", ScriptContext{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(trace) != 4 {
+		t.Fatalf("%d steps traced instead of 4", len(trace))
+	}
+
+	last := trace[len(trace)-1]
+	if last.Token != "DUP" || last.Error == "" {
+		t.Fatalf("last step is %+v, expected the failed DUP", last)
+	}
+}
+
+func TestStackLimit(t *testing.T) {
+	vm := scriptVM{stack: make([]string, MaxStackSize)}
+
+	err := vm.step("1")
+	if err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Fatalf("expected a stack overflow, got %v", err)
+	}
+}
+
+func TestPartialTransaction(t *testing.T) {
+	keys := newTestKeys(t, 3)
+
+	address, err := NewMultisigAddress(2, []string{keys[0].pub, keys[1].pub,
+		keys[2].pub})
+	if err != nil {
+		t.Fatalf("failed to create address: %v", err)
+	}
+
+	tx := NewTransaction(address.String(), "bob", 5)
+	tx.Inputs = []*Input{{PrevTx: Hash{1}}}
+
+	partial := NewPartialTransaction(tx, address, testChainID)
+
+	// the participants sign in any order, the signatures are put back in the
+	// order of the keys
+	err = partial.Sign(keys[2].priv)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	_, err = partial.Finalize()
+	if err == nil {
+		t.Fatal("finalized with a single signature")
+	}
+
+	encoded, err := partial.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	other, err := DecodePartialTransaction(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	err = other.Sign(keys[0].priv)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	err = partial.Merge(other)
+	if err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+
+	final, err := partial.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize: %v", err)
+	}
+
+	ctx := ScriptContext{Tx: final, ChainID: testChainID}
+
+	_, err = ExecuteScripts(final.Inputs[0].UnlockScript, address.Script(), ctx)
+	if err != nil {
+		t.Fatalf("finalized transaction does not unlock: %v", err)
+	}
+
+	ctx.ChainID = "other"
+
+	_, err = ExecuteScripts(final.Inputs[0].UnlockScript, address.Script(), ctx)
+	if err == nil {
+		t.Fatal("finalized transaction unlocks on another network")
+	}
+
+	outsider := newTestKeys(t, 4)[3]
+
+	err = partial.Sign(outsider.priv)
+	if err == nil {
+		t.Fatal("signed with a key outside of the address")
+	}
+
+	err = partial.AddSignature(keys[1].pub, sign(t, tx, keys[1], "other"))
+	if err == nil {
+		t.Fatal("added a signature of another network")
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	tests := []struct {
+		lockTime int64
+		height   int
+		time     int64
+		final    bool
+	}{
+		{0, 0, 0, true},
+		{10, 9, 2000000000, false},
+		{10, 10, 0, true},
+		{LockTimeThreshold - 1, LockTimeThreshold - 2, 2000000000, false},
+		{LockTimeThreshold, 2000000000, LockTimeThreshold - 1, false},
+		{LockTimeThreshold, 0, LockTimeThreshold, true},
+	}
+
+	for _, test := range tests {
+		tx := Transaction{LockTime: test.lockTime}
+
+		final := tx.IsFinal(test.height, test.time)
+		if final != test.final {
+			t.Errorf("lock time %d at height %d and time %d: final is %v",
+				test.lockTime, test.height, test.time, final)
+		}
+	}
+}
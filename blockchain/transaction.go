@@ -1,5 +1,11 @@
 package blockchain
 
+import (
+	"crypto/sha256"
+
+	"golang.org/x/xerrors"
+)
+
 // NewTransaction returns a new transaction
 func NewTransaction(sender, receiver string, amount int) *Transaction {
 	return &Transaction{
@@ -9,9 +15,99 @@ func NewTransaction(sender, receiver string, amount int) *Transaction {
 	}
 }
 
-// Transaction represents a crypto currency transaction
+// NewFeeTransaction returns the transaction fee of the block at the given
+// height, sent by the miner. It is locked at this height, like a coinbase, so
+// that the fees of the same miner have different IDs.
+func NewFeeTransaction(miner, receiver string, height int) *Transaction {
+	tx := NewTransaction(miner, receiver, 1)
+	tx.LockTime = int64(height)
+
+	return tx
+}
+
+// Transaction represents a crypto currency transaction. Its output, ie. the
+// amount sent to the receiver, can be locked by a script. A transaction that
+// spends it must then reference it in its inputs and provide an unlocking
+// script.
+//
+// A transaction with a LockTime cannot be included in a block before the
+// given block height or, if above LockTimeThreshold, the given Unix time in
+// seconds.
+//
+// A transaction with a Vote is a proof of authority vote to add or remove a
+// validator. A transaction with an Evidence reports a proof of stake
+// stakeholder that double-signed.
 type Transaction struct {
-	Sender   string
-	Receiver string
-	Amount   int
+	Sender     string
+	Receiver   string
+	Amount     int
+	LockScript Script
+	Inputs     []*Input
+	LockTime   int64
+	Vote       *Vote
+	Evidence   *SlashingEvidence
+}
+
+// Input references the output of a previous transaction that is spent.
+type Input struct {
+	PrevTx       Hash
+	UnlockScript Script
+}
+
+// IsFinal returns true if the transaction can be included in a block of the
+// given height and Unix time.
+func (t Transaction) IsFinal(height int, unixTime int64) bool {
+	switch {
+	case t.LockTime == 0:
+		return true
+	case t.IsHeightLocked():
+		return int64(height) >= t.LockTime
+	default:
+		return unixTime >= t.LockTime
+	}
+}
+
+// IsHeightLocked returns true if the lock time is a block height
+func (t Transaction) IsHeightLocked() bool {
+	return t.LockTime > 0 && t.LockTime < LockTimeThreshold
+}
+
+// IsTimeLocked returns true if the lock time is a Unix timestamp
+func (t Transaction) IsTimeLocked() bool {
+	return t.LockTime >= LockTimeThreshold
+}
+
+// ID returns the hash of the binary encoding of the transaction
+func (t Transaction) ID() (Hash, error) {
+	encodedTx, err := t.MarshalBinary()
+	if err != nil {
+		return Hash{}, xerrors.Errorf("failed to encode transaction: %v", err)
+	}
+
+	return sha256.Sum256(encodedTx), nil
+}
+
+// SigHash returns the hash that is signed to spend the inputs of the
+// transaction on the network with the given chain ID. It is the hash of the
+// chain ID followed by the transaction with the unlocking scripts removed, as
+// a signature cannot sign itself. A signature is then only valid on its
+// network, and cannot be replayed on another one.
+func (t Transaction) SigHash(chainID string) (Hash, error) {
+	inputs := make([]*Input, len(t.Inputs))
+	for i, input := range t.Inputs {
+		inputs[i] = &Input{PrevTx: input.PrevTx}
+	}
+
+	t.Inputs = inputs
+
+	encodedTx, err := t.MarshalBinary()
+	if err != nil {
+		return Hash{}, xerrors.Errorf("failed to encode transaction: %v", err)
+	}
+
+	e := &encoder{}
+	e.string(chainID)
+	e.Write(encodedTx)
+
+	return sha256.Sum256(e.Bytes()), nil
 }
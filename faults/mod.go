@@ -0,0 +1,236 @@
+// Package faults injects network faults in the requests a node sends to the
+// other nodes: latency, dropped requests, reordering, and partitions between
+// groups of nodes. It lets an instructor split the network and watch the
+// chains fork, then heal it and watch them converge.
+package faults
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// reorderDelay is the maximum extra delay of a request held back, so that the
+// requests sent after it can arrive first.
+const reorderDelay = 200 * time.Millisecond
+
+// Config describes the faults to inject
+type Config struct {
+	// LatencyMs is added to every request, plus a random delay of up to
+	// JitterMs, in milliseconds.
+	LatencyMs int
+	JitterMs  int
+	// DropRate is the probability, from 0 to 1, that a request is lost
+	DropRate float64
+	// ReorderRate is the probability, from 0 to 1, that a request is held back
+	ReorderRate float64
+	// Group is the group of this node. When it is set, the nodes listed in the
+	// other groups cannot be reached.
+	Group string
+	// Groups lists the nodes, as host:port, by group name
+	Groups map[string][]string
+}
+
+// Partitioned tells if the network is split
+func (c Config) Partitioned() bool {
+	return c.Group != "" && len(c.Groups) > 0
+}
+
+// GroupOf returns the group of a node given as host:port, or "" if it is in
+// none.
+func (c Config) GroupOf(host string) string {
+	for name, hosts := range c.Groups {
+		for _, h := range hosts {
+			if h == host {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// Reachable tells if the node given as host:port can be reached
+func (c Config) Reachable(host string) bool {
+	if !c.Partitioned() {
+		return true
+	}
+
+	group := c.GroupOf(host)
+
+	return group == "" || group == c.Group
+}
+
+// Validate returns an error if a value is out of range
+func (c Config) Validate() error {
+	if c.LatencyMs < 0 || c.JitterMs < 0 {
+		return xerrors.Errorf("latency and jitter must be positive")
+	}
+
+	if c.DropRate < 0 || c.DropRate > 1 {
+		return xerrors.Errorf("drop rate must be between 0 and 1")
+	}
+
+	if c.ReorderRate < 0 || c.ReorderRate > 1 {
+		return xerrors.Errorf("reorder rate must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// Stats counts the requests affected by the faults
+type Stats struct {
+	Requests  uint64
+	Blocked   uint64
+	Dropped   uint64
+	Reordered uint64
+}
+
+// NewInjector returns an injector without faults, sending the requests with
+// next. The random faults are drawn from the seed, so that a node with a
+// seeded entropy injects the same faults.
+func NewInjector(next http.RoundTripper, seed int64) *Injector {
+	return &Injector{
+		next: next,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Injector delays, drops or blocks the requests according to its config
+//
+// - implements http.RoundTripper
+type Injector struct {
+	sync.Mutex
+
+	next   http.RoundTripper
+	rand   *rand.Rand
+	config Config
+	stats  Stats
+}
+
+// Config returns the current config
+func (i *Injector) Config() Config {
+	i.Lock()
+	defer i.Unlock()
+
+	return i.config
+}
+
+// SetConfig replaces the config
+func (i *Injector) SetConfig(config Config) error {
+	err := config.Validate()
+	if err != nil {
+		return xerrors.Errorf("invalid config: %v", err)
+	}
+
+	i.Lock()
+	i.config = config
+	i.Unlock()
+
+	return nil
+}
+
+// Stats returns the number of affected requests since the node started
+func (i *Injector) Stats() Stats {
+	i.Lock()
+	defer i.Unlock()
+
+	return i.stats
+}
+
+// RoundTrip implements http.RoundTripper
+func (i *Injector) RoundTrip(req *http.Request) (*http.Response, error) {
+	i.Lock()
+
+	config := i.config
+	i.stats.Requests++
+
+	if !config.Reachable(req.URL.Host) {
+		i.stats.Blocked++
+		i.Unlock()
+
+		return nil, xerrors.Errorf("%s is in group %s, partitioned from %s",
+			req.URL.Host, config.GroupOf(req.URL.Host), config.Group)
+	}
+
+	if i.rand.Float64() < config.DropRate {
+		i.stats.Dropped++
+		i.Unlock()
+
+		return nil, xerrors.Errorf("request to %s dropped", req.URL.Host)
+	}
+
+	delay := time.Duration(config.LatencyMs) * time.Millisecond
+	if config.JitterMs > 0 {
+		delay += time.Duration(i.rand.Int63n(int64(config.JitterMs)+1)) * time.Millisecond
+	}
+
+	if i.rand.Float64() < config.ReorderRate {
+		i.stats.Reordered++
+		delay += time.Duration(i.rand.Int63n(int64(reorderDelay)))
+	}
+
+	i.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	return i.next.RoundTrip(req)
+}
+
+// ParseGroups parses groups given one per line as "name: host:port, ..."
+func ParseGroups(s string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, xerrors.Errorf("group should be <name>: <host:port>, ...: %s", line)
+		}
+
+		name := strings.TrimSpace(parts[0])
+
+		for _, host := range strings.Split(parts[1], ",") {
+			host = strings.TrimSpace(host)
+			if host != "" {
+				groups[name] = append(groups[name], host)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// FormatGroups formats the groups as parsed by ParseGroups, sorted by name
+func FormatGroups(groups map[string][]string) string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + ": " + strings.Join(groups[name], ", ")
+	}
+
+	return strings.Join(lines, "\n")
+}
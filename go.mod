@@ -1,8 +1,5 @@
 module dummy-blockchain
 
-go 1.14
+go 1.21
 
-require (
-	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
-	github.com/google/uuid v1.1.1
-)
+require golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
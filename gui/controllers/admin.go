@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// adminTokenField is the form field of the admin token, for the HTML pages
+// which cannot set the Authorization header
+const adminTokenField = "admin_token"
+
+// CheckAdmin returns an error if the request is not allowed to run an admin
+// action. The token is given as "Authorization: Bearer <token>", or in the
+// admin_token field of a form. The admin actions are disabled if the node has
+// no token.
+func CheckAdmin(r *http.Request, token string) error {
+	if token == "" {
+		return xerrors.Errorf("admin actions are disabled, start the node " +
+			"with -admin-token")
+	}
+
+	given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		given = r.PostFormValue(adminTokenField)
+	}
+
+	if given == "" {
+		return xerrors.Errorf("missing admin token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return xerrors.Errorf("wrong admin token")
+	}
+
+	return nil
+}
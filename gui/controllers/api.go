@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/openapi"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIPrefix is the path prefix of the versioned REST API
+const APIPrefix = "/api/v1"
+
+// APIHandler returns the handler of the versioned REST API. Unlike the legacy
+// endpoints, it answers 405 with the Allow header on a wrong method, only
+// accepts JSON bodies, and does not change state on GET.
+func APIHandler(blockchain *bc.Blockchain, me string) http.Handler {
+	mux := http.NewServeMux()
+
+	for path, methods := range APIRoutes(blockchain, me) {
+		mux.Handle(path, methods)
+	}
+
+	mux.HandleFunc(APIPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		RenderJSONError(w, "no endpoint at "+r.URL.Path, http.StatusNotFound)
+	})
+
+	return mux
+}
+
+// APIRoutes returns the endpoints of the versioned REST API, by path
+func APIRoutes(blockchain *bc.Blockchain, me string) map[string]Methods {
+	routes := make(map[string]Methods)
+
+	handle := func(path string, methods Methods) {
+		routes[APIPrefix+path] = methods
+	}
+
+	with := func(handler func(http.ResponseWriter, *http.Request, *bc.Blockchain)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, blockchain)
+		}
+	}
+
+	handle("/chain", Methods{
+		http.MethodGet: with(getChainREST),
+	})
+	handle("/chain/valid", Methods{
+		http.MethodGet: with(isValidREST),
+	})
+	handle("/chain/replace", Methods{
+		http.MethodPost: with(replaceChainREST),
+	})
+	handle("/blocks", Methods{
+		http.MethodGet: with(blocksREST),
+		http.MethodPost: func(w http.ResponseWriter, r *http.Request) {
+			mineREST(w, r, blockchain, me)
+		},
+	})
+	handle("/blocks/latest", Methods{
+		http.MethodGet: with(latestBlockREST),
+	})
+	handle("/transactions", Methods{
+		http.MethodGet:  with(transactionsREST),
+		http.MethodPost: requireJSON(with(AddTransactionPost)),
+	})
+	handle("/nodes", Methods{
+		http.MethodGet:  with(nodesREST),
+		http.MethodPost: requireJSON(with(connectNodeHandler)),
+	})
+	handle("/votes", Methods{
+		http.MethodPost: requireJSON(with(voteREST)),
+	})
+	handle("/slashings", Methods{
+		http.MethodPost: requireJSON(with(slashREST)),
+	})
+	handle("/scripts/trace", Methods{
+		http.MethodPost: requireJSON(with(traceScriptREST)),
+	})
+	handle("/partial_transactions", Methods{
+		http.MethodPost: requireJSON(with(addPartialTransactionREST)),
+	})
+	handle("/partial_transactions/finalize", Methods{
+		http.MethodPost: requireJSON(with(finalizePartialTransactionREST)),
+	})
+
+	return routes
+}
+
+// Methods routes a request according to its method. Other methods are
+// answered with 405 and the Allow header.
+//
+// - implements http.Handler
+type Methods map[string]http.HandlerFunc
+
+// ServeHTTP implements http.Handler
+func (m Methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, found := m[r.Method]
+	if found {
+		handler(w, r)
+		return
+	}
+
+	allowed := make([]string, 0, len(m)+1)
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	RenderJSONError(w, r.Method+" is not allowed on "+r.URL.Path,
+		http.StatusMethodNotAllowed)
+}
+
+// ErrorResponse is the body of the REST errors
+type ErrorResponse struct {
+	Error APIError
+}
+
+// APIError describes a REST error. Fields is set when the body of the request
+// does not match the OpenAPI spec.
+type APIError struct {
+	Code    int
+	Status  string
+	Message string
+	Fields  []openapi.FieldError `json:",omitempty"`
+}
+
+// RenderJSONError writes a REST error as JSON
+func RenderJSONError(w http.ResponseWriter, message string, code int) {
+	RenderJSONFieldErrors(w, message, code, nil)
+}
+
+// RenderJSONFieldErrors writes a REST error as JSON, with the invalid fields
+// of the request body.
+func RenderJSONFieldErrors(w http.ResponseWriter, message string, code int,
+	fields []openapi.FieldError) {
+
+	resp := ErrorResponse{
+		Error: APIError{
+			Code:    code,
+			Status:  http.StatusText(code),
+			Message: message,
+			Fields:  fields,
+		},
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		http.Error(w, message, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(respJSON)
+}
+
+// IsJSON tells if the body of the request is declared as JSON
+func IsJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// requireJSON rejects the requests whose body is not JSON
+func requireJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !IsJSON(r) {
+			RenderJSONError(w, "the body must be application/json",
+				http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next(w, r)
+	}
+}
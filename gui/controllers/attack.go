@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"dummy-blockchain/attack"
+	bc "dummy-blockchain/blockchain"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultHashShare is the hash power of the attacker, in percent, used for
+// the probabilities when none is given.
+const defaultHashShare = 30
+
+// maxDepth is the deepest confirmation shown in the probabilities
+const maxDepth = 10
+
+// AttackHandler is the HTML endpoint to run the double-spend demonstration.
+// attacker is nil if the node is not started as an attacker.
+func AttackHandler(renderer *Renderer, blockchain *bc.Blockchain,
+	attacker *attack.Attacker) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			attackGet(w, r, renderer, blockchain, attacker)
+		case http.MethodPost:
+			attackPost(w, r, renderer, blockchain, attacker)
+		}
+	}
+}
+
+func attackGet(w http.ResponseWriter, r *http.Request, renderer *Renderer,
+	blockchain *bc.Blockchain, attacker *attack.Attacker) {
+
+	flashStr := ""
+	err := r.ParseForm()
+	if err == nil {
+		flashStr = r.PostForm.Get("flash")
+	}
+
+	hashShare, err := strconv.ParseFloat(r.URL.Query().Get("share"), 64)
+	if err != nil || hashShare <= 0 || hashShare >= 100 {
+		hashShare = defaultHashShare
+	}
+
+	type payment struct {
+		ID         bc.Hash
+		BlockIndex int
+		Tx         *bc.Transaction
+	}
+
+	type risk struct {
+		Depth       int
+		Probability string
+	}
+
+	type viewData struct {
+		Title     string
+		Flash     string
+		Enabled   bool
+		Status    attack.Status
+		Payments  []payment
+		HashShare float64
+		Risks     []risk
+	}
+
+	p := &viewData{
+		Title:     "Double-spend attack",
+		Flash:     flashStr,
+		Enabled:   attacker != nil,
+		HashShare: hashShare,
+	}
+
+	if attacker != nil {
+		p.Status = attacker.Status()
+	}
+
+	// the payments that can be reverted, the latest first. The last
+	// transaction of a mined block is its fee.
+	for i := len(blockchain.Chain) - 1; i > 0 && len(p.Payments) < 10; i-- {
+		block := blockchain.Chain[i]
+		if len(block.Transactions) == 0 {
+			continue
+		}
+
+		for _, tx := range block.Transactions[:len(block.Transactions)-1] {
+			id, err := tx.ID()
+			if err != nil {
+				continue
+			}
+
+			p.Payments = append(p.Payments, payment{ID: id, BlockIndex: block.Index, Tx: tx})
+		}
+	}
+
+	for z := 0; z <= maxDepth; z++ {
+		p.Risks = append(p.Risks, risk{
+			Depth: z,
+			Probability: fmt.Sprintf("%.7f",
+				attack.CatchUpProbability(hashShare/100, z)),
+		})
+	}
+
+	renderer.Render(w, "attack", p)
+}
+
+func attackPost(w http.ResponseWriter, r *http.Request, renderer *Renderer,
+	blockchain *bc.Blockchain, attacker *attack.Attacker) {
+
+	if attacker == nil {
+		renderer.RenderHTTPError(w, "the node is not an attacker, start it "+
+			"with -attacker", http.StatusBadRequest)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var flashMsg string
+
+	switch r.PostForm.Get("action") {
+	case "start":
+		id, err := bc.ParseHash(strings.TrimSpace(r.PostForm.Get("tx")))
+		if err != nil {
+			renderer.RenderHTTPError(w, "invalid transaction id: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		err = attacker.Start(r.Context(), id)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flashMsg = "Attack started"
+	case "mine":
+		err = attacker.MineBlock(r.Context())
+		flashMsg = "Private block mined"
+	case "release":
+		err = attacker.Release(r.Context())
+		flashMsg = "Private branch released"
+	case "run":
+		err = attacker.Run(r.Context())
+		flashMsg = "Private branch mined and released"
+	case "reset":
+		attacker.Reset()
+		flashMsg = "Attack reset"
+	default:
+		renderer.RenderHTTPError(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	formData := url.Values{
+		"flash": {flashMsg},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/attack", strings.NewReader(formData.Encode()))
+	if err != nil {
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+
+	attackGet(w, req, renderer, blockchain, attacker)
+}
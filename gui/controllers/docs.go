@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"dummy-blockchain/openapi"
+	"net/http"
+)
+
+// OpenAPIHandler is the endpoint serving the OpenAPI specification of the REST
+// API: /openapi.json
+func OpenAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(openapi.Raw)
+		}
+	}
+}
+
+// DocsHandler is the HTML endpoint to browse and try the REST API
+func DocsHandler(renderer *Renderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			docsGet(w, r, renderer)
+		}
+	}
+}
+
+func docsGet(w http.ResponseWriter, r *http.Request, renderer *Renderer) {
+
+	type viewData struct {
+		Title string
+	}
+
+	p := &viewData{
+		Title: "API",
+	}
+
+	renderer.Render(w, "docs", p)
+}
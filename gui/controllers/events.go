@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// keepAlivePeriod is how often a comment is sent on idle event streams, so
+// that proxies do not close them.
+const keepAlivePeriod = 15 * time.Second
+
+// EventsHandler is the Server-Sent Events endpoint streaming the events of the
+// node: block-added, tx-added, reorg and peer-changed.
+func EventsHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			eventsStream(w, r, blockchain)
+		}
+	}
+}
+
+func eventsStream(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := blockchain.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlivePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
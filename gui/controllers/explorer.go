@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// BlockHandler is the HTML endpoint to view a block, given its height or its
+// hash: /block/{height|hash}
+func BlockHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			blockGet(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// TxHandler is the HTML endpoint to view a transaction: /tx/{id}
+func TxHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			txGet(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// AddressHandler is the HTML endpoint to view the balance and the history of
+// an address: /address/{address}
+func AddressHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			addressGet(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// SearchHandler redirects to the page of a height, a hash, a transaction ID,
+// or an address: /search?q={query}
+func SearchHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			searchGet(w, r, blockchain)
+		}
+	}
+}
+
+func blockGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	id := strings.TrimPrefix(r.URL.Path, "/block/")
+	block := findBlock(blockchain, id)
+	if block == nil {
+		renderer.RenderHTTPError(w, "block not found", http.StatusNotFound)
+		return
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type viewData struct {
+		Title string
+		Block *bc.Block
+		Hash  bc.Hash
+		Next  *bc.Block
+	}
+
+	p := &viewData{
+		Title: "Block #" + strconv.Itoa(block.Index),
+		Block: block,
+		Hash:  hash,
+		Next:  blockchain.GetBlock(block.Index + 1),
+	}
+
+	renderer.Render(w, "block", p)
+}
+
+func txGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	id, err := bc.ParseHash(strings.TrimPrefix(r.URL.Path, "/tx/"))
+	if err != nil {
+		renderer.RenderHTTPError(w, "invalid transaction ID: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	loc := blockchain.LocateTransaction(id)
+	if loc == nil {
+		renderer.RenderHTTPError(w, "transaction not found", http.StatusNotFound)
+		return
+	}
+
+	type viewData struct {
+		Title         string
+		Loc           *bc.TxLocation
+		Confirmations int
+	}
+
+	p := &viewData{
+		Title: "Transaction",
+		Loc:   loc,
+	}
+
+	if loc.Block != nil {
+		p.Confirmations = len(blockchain.Chain) - loc.Block.Index
+	}
+
+	renderer.Render(w, "tx", p)
+}
+
+func addressGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	// addresses are escaped with urlquery in the templates
+	address, err := url.QueryUnescape(strings.TrimPrefix(r.URL.EscapedPath(),
+		"/address/"))
+	if err != nil {
+		renderer.RenderHTTPError(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type viewData struct {
+		Title   string
+		Address string
+		Balance int
+		History []*bc.TxLocation
+	}
+
+	p := &viewData{
+		Title:   "Address",
+		Address: address,
+		Balance: blockchain.GetBalance(address),
+		History: blockchain.AddressHistory(address),
+	}
+
+	renderer.Render(w, "address", p)
+}
+
+func searchGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if findBlock(blockchain, query) != nil {
+		http.Redirect(w, r, "/block/"+query, http.StatusSeeOther)
+		return
+	}
+
+	id, err := bc.ParseHash(query)
+	if err == nil && blockchain.LocateTransaction(id) != nil {
+		http.Redirect(w, r, "/tx/"+query, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/address/"+url.QueryEscape(query), http.StatusSeeOther)
+}
+
+// findBlock returns the block with the given height or hash, or nil.
+func findBlock(blockchain *bc.Blockchain, id string) *bc.Block {
+	height, err := strconv.Atoi(id)
+	if err == nil {
+		return blockchain.GetBlock(height)
+	}
+
+	hash, err := bc.ParseHash(id)
+	if err == nil {
+		return blockchain.FindBlock(hash)
+	}
+
+	return nil
+}
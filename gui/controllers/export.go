@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/logs"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// maxImportSize is the maximum size of an imported chain
+const maxImportSize = 64 << 20
+
+// ExportHandler is the REST endpoint to download the chain as a file
+func ExportHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			exportREST(w, r, blockchain)
+		}
+	}
+}
+
+// ImportHandler is the REST endpoint to replace the chain by an exported one.
+// Forcing the replacement of a preferred chain is an admin action.
+func ImportHandler(blockchain *bc.Blockchain, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			importREST(w, r, blockchain, adminToken)
+		}
+	}
+}
+
+func exportREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	formatStr := r.URL.Query().Get("format")
+	if formatStr == "" {
+		formatStr = string(bc.JSONLines)
+	}
+
+	format, err := bc.ParseFormat(formatStr)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if format == bc.Binary {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="chain`+format.FileExtension()+`"`)
+
+	err = bc.ExportChain(w, blockchain.Chain, format)
+	if err != nil {
+		// the response is already started, we can only log the error
+		logs.FromContext(r.Context()).Warn("failed to export the chain",
+			"error", err)
+	}
+}
+
+func importREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain,
+	adminToken string) {
+
+	force := false
+
+	forceStr := r.URL.Query().Get("force")
+	if forceStr != "" {
+		var err error
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			RenderJSONError(w, "failed to convert force: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	if force {
+		err := CheckAdmin(r, adminToken)
+		if err != nil {
+			RenderJSONError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	chain, err := bc.ImportChain(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reorg, err := blockchain.SetChain(chain, force)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp = struct {
+		Message   string
+		Fork      int
+		OldHeight int
+		NewHeight int
+	}{
+		"Chain imported",
+		reorg.Fork,
+		reorg.OldHeight,
+		reorg.NewHeight,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/faults"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// FaultsHandler is the HTML endpoint to inject faults in the requests to the
+// other nodes.
+func FaultsHandler(renderer *Renderer, blockchain *bc.Blockchain,
+	injector *faults.Injector, adminToken string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			faultsGet(w, r, renderer, blockchain, injector)
+		case http.MethodPost:
+			faultsPost(w, r, renderer, blockchain, injector, adminToken)
+		}
+	}
+}
+
+// FaultsRESTHandler is the REST endpoint to get and set the faults. Setting
+// them is an admin action.
+func FaultsRESTHandler(injector *faults.Injector, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			faultsREST(w, r, injector)
+		case http.MethodPut:
+			setFaultsREST(w, r, injector, adminToken)
+		}
+	}
+}
+
+func faultsGet(w http.ResponseWriter, r *http.Request, renderer *Renderer,
+	blockchain *bc.Blockchain, injector *faults.Injector) {
+
+	flashStr := ""
+	err := r.ParseForm()
+	if err == nil {
+		flashStr = r.PostForm.Get("flash")
+	}
+
+	type peer struct {
+		Host      string
+		Group     string
+		Reachable bool
+	}
+
+	type viewData struct {
+		Title          string
+		Flash          string
+		Config         faults.Config
+		Stats          faults.Stats
+		Groups         string
+		DropPercent    float64
+		ReorderPercent float64
+		Peers          []peer
+	}
+
+	config := injector.Config()
+
+	p := &viewData{
+		Title:          "Network faults",
+		Flash:          flashStr,
+		Config:         config,
+		Stats:          injector.Stats(),
+		Groups:         faults.FormatGroups(config.Groups),
+		DropPercent:    config.DropRate * 100,
+		ReorderPercent: config.ReorderRate * 100,
+	}
+
+	for _, node := range blockchain.Nodes {
+		host := node.Host + ":" + strconv.Itoa(node.Port)
+		p.Peers = append(p.Peers, peer{
+			Host:      host,
+			Group:     config.GroupOf(host),
+			Reachable: config.Reachable(host),
+		})
+	}
+
+	renderer.Render(w, "faults", p)
+}
+
+func faultsPost(w http.ResponseWriter, r *http.Request, renderer *Renderer,
+	blockchain *bc.Blockchain, injector *faults.Injector, adminToken string) {
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = CheckAdmin(r, adminToken)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var config faults.Config
+	var flashMsg string
+
+	switch r.PostForm.Get("action") {
+	case "heal":
+		config = injector.Config()
+		config.Group = ""
+		flashMsg = "Partition healed, replace the chain to converge"
+	case "reset":
+		flashMsg = "All the faults removed"
+	default:
+		config, err = faultsFromForm(r.PostForm)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flashMsg = "Faults updated"
+	}
+
+	err = injector.SetConfig(config)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	formData := url.Values{
+		"flash": {flashMsg},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/faults", strings.NewReader(formData.Encode()))
+	if err != nil {
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+
+	faultsGet(w, req, renderer, blockchain, injector)
+}
+
+// faultsFromForm reads the config of the form, where the rates are given in
+// percent.
+func faultsFromForm(form url.Values) (faults.Config, error) {
+	var config faults.Config
+	var err error
+
+	ints := map[string]*int{
+		"latency": &config.LatencyMs,
+		"jitter":  &config.JitterMs,
+	}
+
+	for name, dst := range ints {
+		if form.Get(name) == "" {
+			continue
+		}
+
+		*dst, err = strconv.Atoi(form.Get(name))
+		if err != nil {
+			return config, xerrors.Errorf("failed to convert %s: %v", name, err)
+		}
+	}
+
+	rates := map[string]*float64{
+		"drop":    &config.DropRate,
+		"reorder": &config.ReorderRate,
+	}
+
+	for name, dst := range rates {
+		if form.Get(name) == "" {
+			continue
+		}
+
+		percent, err := strconv.ParseFloat(form.Get(name), 64)
+		if err != nil {
+			return config, xerrors.Errorf("failed to convert %s: %v", name, err)
+		}
+
+		*dst = percent / 100
+	}
+
+	config.Group = strings.TrimSpace(form.Get("group"))
+
+	config.Groups, err = faults.ParseGroups(form.Get("groups"))
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+func faultsREST(w http.ResponseWriter, r *http.Request, injector *faults.Injector) {
+
+	var resp = struct {
+		Config faults.Config
+		Stats  faults.Stats
+	}{
+		injector.Config(),
+		injector.Stats(),
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+func setFaultsREST(w http.ResponseWriter, r *http.Request, injector *faults.Injector,
+	adminToken string) {
+
+	err := CheckAdmin(r, adminToken)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var config faults.Config
+	err = json.NewDecoder(r.Body).Decode(&config)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = injector.SetConfig(config)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	faultsREST(w, r, injector)
+}
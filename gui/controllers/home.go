@@ -4,18 +4,21 @@ import (
 	bc "dummy-blockchain/blockchain"
 	"encoding/json"
 	"net/http"
-	"text/template"
+	"strconv"
 )
 
+// blocksPerPage is the number of blocks displayed on a page of the home
+const blocksPerPage = 10
+
 // HomeHandler is the HTTP handler to view the chain
-func HomeHandler(blockchain *bc.Blockchain, me string) http.HandlerFunc {
+func HomeHandler(renderer *Renderer, blockchain *bc.Blockchain, me string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			homeGet(w, r, blockchain, me)
+			homeGet(w, r, renderer, blockchain, me)
 		case http.MethodPost:
 			// to post flash
-			homeGet(w, r, blockchain, me)
+			homeGet(w, r, renderer, blockchain, me)
 		}
 	}
 }
@@ -30,63 +33,100 @@ func GetChainHandler(blockchain *bc.Blockchain) http.HandlerFunc {
 	}
 }
 
-func homeGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain, me string) {
+func homeGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain, me string) {
 
 	if r.URL.Path != "/" {
-		RenderHTTPError(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderer.RenderHTTPError(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
 	}
 
-	t, err := template.ParseFiles("gui/views/layout.gohtml", "gui/views/home.gohtml")
-	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+	// page 1 holds the most recent blocks
+	page := 1
+	pageStr := r.URL.Query().Get("page")
+	if pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			renderer.RenderHTTPError(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+
+	numPages := (len(blockchain.Chain) + blocksPerPage - 1) / blocksPerPage
+	if page > numPages {
+		renderer.RenderHTTPError(w, "page not found", http.StatusNotFound)
 		return
 	}
 
-	type viewData struct {
-		Title string
-		BC    *bc.Blockchain
+	to := len(blockchain.Chain) - (page-1)*blocksPerPage
+	from := to - blocksPerPage
+	if from < 0 {
+		from = 0
 	}
 
-	p := &viewData{
-		Title: "Home",
-		BC:    blockchain,
+	// pendingTx tells how long a pending transaction is still locked
+	type pendingTx struct {
+		*bc.Transaction
+		BlocksLeft  int64
+		SecondsLeft int64
 	}
 
-	err = t.ExecuteTemplate(w, "layout", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	nextIndex := int64(blockchain.GetPreviousBlock().Index + 1)
+	now := blockchain.Clock.Now().Unix()
+
+	pending := make([]pendingTx, len(blockchain.Transactions))
+	for i, tx := range blockchain.Transactions {
+		pending[i].Transaction = tx
+
+		switch {
+		case tx.IsHeightLocked() && tx.LockTime > nextIndex:
+			pending[i].BlocksLeft = tx.LockTime - nextIndex
+		case tx.IsTimeLocked() && tx.LockTime > now:
+			pending[i].SecondsLeft = tx.LockTime - now
+		}
 	}
-}
 
-// RenderHTTPError is a utility function to render a user-friendly error
-func RenderHTTPError(w http.ResponseWriter, message string, code int) {
+	type viewData struct {
+		Title     string
+		BC        *bc.Blockchain
+		Blocks    []*bc.Block
+		From      int
+		To        int
+		OlderPage int
+		NewerPage int
+		Pending   []pendingTx
+	}
 
-	var viewData = struct {
-		Title   string
-		Message string
-		Code    int
-	}{
-		"Something bad happened",
-		message,
-		code,
+	p := &viewData{
+		Title:   "Home",
+		BC:      blockchain,
+		Blocks:  blockchain.Chain[from:to],
+		From:    from,
+		To:      to - 1,
+		Pending: pending,
 	}
 
-	t, err := template.ParseFiles("gui/views/error.gohtml")
-	if err != nil {
-		http.Error(w, message, code)
-		return
+	if page < numPages {
+		p.OlderPage = page + 1
 	}
 
-	err = t.ExecuteTemplate(w, "error", viewData)
-	if err != nil {
-		http.Error(w, message, code)
-		return
+	if page > 1 {
+		p.NewerPage = page - 1
 	}
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(code)
+	renderer.Render(w, "home", p)
+}
+
+// IsValidHandler is the REST endpoint to check the validity of the chain
+func IsValidHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			isValidREST(w, r, blockchain)
+		default:
+			RenderJSONError(w, "only GET is allowed", http.StatusBadRequest)
+		}
+	}
 }
 
 func getChainREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
@@ -98,7 +138,31 @@ func getChainREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockch
 
 	respJSON, err := json.MarshalIndent(resp, "", "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+func isValidREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	isValid, err := blockchain.IsCHainValid(blockchain.Chain)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp = struct {
+		IsValid bool
+	}{
+		isValid,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -9,17 +9,16 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"text/template"
 )
 
 // MineHandler is the HTTP handler
-func MineHandler(blockchain *bc.Blockchain, me string) http.HandlerFunc {
+func MineHandler(renderer *Renderer, blockchain *bc.Blockchain, me string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			mineGet(w, r, blockchain)
+			mineGet(w, r, renderer, blockchain)
 		case http.MethodPost:
-			minePost(w, r, blockchain, me)
+			minePost(w, r, renderer, blockchain, me)
 		}
 	}
 }
@@ -34,16 +33,10 @@ func MineRESTHandler(blockchain *bc.Blockchain, me string) http.HandlerFunc {
 	}
 }
 
-func mineGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
-
-	t, err := template.ParseFiles("gui/views/layout.gohtml", "gui/views/mine.gohtml")
-	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+func mineGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	flashStr := ""
-	err = r.ParseForm()
+	err := r.ParseForm()
 	if err == nil {
 		flashStr = r.PostForm.Get("flash")
 	}
@@ -58,37 +51,30 @@ func mineGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain)
 		Flash: flashStr,
 	}
 
-	err = t.ExecuteTemplate(w, "layout", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	renderer.Render(w, "mine", p)
 }
 
-func minePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain, me string) {
+func minePost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain, me string) {
 
-	previousBblock := blockchain.GetPreviousBlock()
-	proof := blockchain.ProofOfWork(previousBblock.Proof)
-	previousHash, err := previousBblock.Hash()
+	block, err := blockchain.MineBlock(r.Context(), me)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// transaction fee, sending money to myself
-	t := bc.NewTransaction(blockchain.Address, me, 1)
-	blockchain.AddTransaction(t)
-
-	block := blockchain.CreateBlock(proof, previousHash)
-
 	flashMsg := fmt.Sprintf("New block with index %d mined! We found the "+
 		"nounce %d.", block.Index, block.Proof)
+	if block.Signer != "" {
+		flashMsg = fmt.Sprintf("New block with index %d signed by %s!",
+			block.Index, block.Signer)
+	}
 	formData := url.Values{
 		"flash": {flashMsg},
 	}
 
 	req, err := http.NewRequest(http.MethodPost, "/mine", strings.NewReader(formData.Encode()))
 	if err != nil {
-		RenderHTTPError(w, "failed to POST status: "+err.Error(),
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
 			http.StatusInternalServerError)
 		return
 	}
@@ -96,24 +82,16 @@ func minePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain,
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
 
-	mineGet(w, req, blockchain)
+	mineGet(w, req, renderer, blockchain)
 }
 
 func mineREST(w http.ResponseWriter, r *http.Request, blockchain *blockchain.Blockchain, me string) {
 
-	previousBblock := blockchain.GetPreviousBlock()
-	proof := blockchain.ProofOfWork(previousBblock.Proof)
-	previousHash, err := previousBblock.Hash()
+	block, err := blockchain.MineBlock(r.Context(), me)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	// transaction fee, sending money to myself
-	t := bc.NewTransaction(blockchain.Address, me, 1)
-	blockchain.AddTransaction(t)
-
-	block := blockchain.CreateBlock(proof, previousHash)
 	var resp = struct {
 		Message string
 		Block   *bc.Block
@@ -124,7 +102,7 @@ func mineREST(w http.ResponseWriter, r *http.Request, blockchain *blockchain.Blo
 
 	respJSON, err := json.MarshalIndent(resp, "", "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
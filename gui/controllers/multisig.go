@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MultisigHandler is the HTML endpoint to create and co-sign multisig
+// transactions
+func MultisigHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			multisigGet(w, r, renderer, blockchain)
+		case http.MethodPost:
+			multisigPost(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// AddPartialTransactionHandler is the REST endpoint to add or merge a
+// partially-signed transaction
+func AddPartialTransactionHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			addPartialTransactionREST(w, r, blockchain)
+		}
+	}
+}
+
+// FinalizePartialTransactionHandler is the REST endpoint to move a fully
+// signed partial transaction to the pending transactions
+func FinalizePartialTransactionHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			finalizePartialTransactionREST(w, r, blockchain)
+		}
+	}
+}
+
+func multisigGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	flashStr := ""
+	err := r.ParseForm()
+	if err == nil {
+		flashStr = r.PostForm.Get("flash")
+	}
+
+	type partialTx struct {
+		*bc.PartialTransaction
+		ID      bc.Hash
+		Encoded string
+	}
+
+	partials := make([]partialTx, len(blockchain.PartialTransactions))
+	for i, p := range blockchain.PartialTransactions {
+		partials[i].PartialTransaction = p
+
+		partials[i].ID, err = p.ID()
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		partials[i].Encoded, err = p.Encode()
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type viewData struct {
+		Title    string
+		Flash    string
+		Partials []partialTx
+	}
+
+	p := &viewData{
+		Title:    "Multisig",
+		Flash:    flashStr,
+		Partials: partials,
+	}
+
+	renderer.Render(w, "multisig", p)
+}
+
+func multisigPost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var flashMsg string
+
+	switch r.PostForm.Get("action") {
+	case "keygen":
+		pubKey, privKey, err := bc.NewKeyPair(blockchain.Entropy)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("New key pair generated. Keep the private key "+
+			"secret! Public key: %s - Private key: %s", pubKey, privKey)
+
+	case "address":
+		m, err := strconv.Atoi(r.PostForm.Get("m"))
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to convert M: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		address, err := bc.NewMultisigAddress(m,
+			strings.Fields(r.PostForm.Get("pubkeys")))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("New multisig address %s. Send funds to it "+
+			"with the lock script: %s", address, address.Script())
+
+	case "create":
+		prevTxID, err := bc.ParseHash(r.PostForm.Get("prevtx"))
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to read spent tx ID: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		prevTx := blockchain.FindTransaction(prevTxID)
+		if prevTx == nil {
+			renderer.RenderHTTPError(w, "Spent transaction not found",
+				http.StatusNotFound)
+			return
+		}
+
+		address, err := bc.ParseMultisigScript(prevTx.LockScript)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		amount, err := strconv.Atoi(r.PostForm.Get("amount"))
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to convert amount: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		tx := bc.NewTransaction(address.String(), r.PostForm.Get("receiver"), amount)
+		tx.Inputs = []*bc.Input{{PrevTx: prevTxID}}
+
+		_, err = blockchain.AddPartialTransaction(bc.NewPartialTransaction(tx, address,
+			blockchain.Genesis.ChainID))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("New multisig transaction created. It needs %d "+
+			"signature(s).", address.M)
+
+	case "import":
+		partial, err := bc.DecodePartialTransaction(r.PostForm.Get("encoded"))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		partial, err = blockchain.AddPartialTransaction(partial)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("Partial transaction imported, it has %s.",
+			partial.Status())
+
+	case "sign":
+		id, err := bc.ParseHash(r.PostForm.Get("id"))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		partial := blockchain.FindPartialTransaction(id)
+		if partial == nil {
+			renderer.RenderHTTPError(w, "Partial transaction not found",
+				http.StatusNotFound)
+			return
+		}
+
+		err = partial.Sign(r.PostForm.Get("privkey"))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("Transaction signed, it has %s.", partial.Status())
+
+	case "finalize":
+		id, err := bc.ParseHash(r.PostForm.Get("id"))
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		index, err := blockchain.FinalizePartialTransaction(id)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flashMsg = fmt.Sprintf("Multisig transaction added to the pool. "+
+			"The transaction should be added in block #%d", index)
+
+	default:
+		renderer.RenderHTTPError(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+
+	formData := url.Values{
+		"flash": {flashMsg},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/multisig", strings.NewReader(formData.Encode()))
+	if err != nil {
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+
+	multisigGet(w, req, renderer, blockchain)
+}
+
+func addPartialTransactionREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var partial bc.PartialTransaction
+	err := json.NewDecoder(r.Body).Decode(&partial)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := blockchain.AddPartialTransaction(&partial)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := stored.ID()
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp = struct {
+		Message            string
+		ID                 bc.Hash
+		IsComplete         bool
+		PartialTransaction *bc.PartialTransaction
+	}{
+		"Partial transaction added",
+		id,
+		stored.IsComplete(),
+		stored,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(respJSON)
+}
+
+func finalizePartialTransactionREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var finalizeRequest struct {
+		ID bc.Hash
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&finalizeRequest)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := blockchain.FinalizePartialTransaction(finalizeRequest.ID)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp = struct {
+		Message    string
+		BlockIndex int
+	}{
+		"Transaction added",
+		index,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(respJSON)
+}
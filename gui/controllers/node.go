@@ -5,66 +5,73 @@ import (
 	bc "dummy-blockchain/blockchain"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"text/template"
 )
 
 // NodeHandler is the HTML endpoint to add a node
-func NodeHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+func NodeHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			nodeGet(w, r, blockchain)
+			nodeGet(w, r, renderer, blockchain)
 		case http.MethodPost:
-			nodePost(w, r, blockchain)
+			nodePost(w, r, renderer, blockchain)
 		}
 	}
 }
 
-// ConnectNodesHandler is the REST endpoint to add new nodes
+// ConnectNodesHandler is the REST endpoint to add new nodes, after a
+// handshake with each of them
 func ConnectNodesHandler(blockchain *bc.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			nodePost(w, r, blockchain)
+			connectNodeHandler(w, r, blockchain)
 		}
 	}
 }
 
-func nodeGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
-
-	t, err := template.ParseFiles("gui/views/layout.gohtml", "gui/views/node.gohtml")
-	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
-		return
+// HandshakeHandler is the REST endpoint giving the handshake of the node, or
+// answering the handshake of another node
+func HandshakeHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getHandshakeREST(w, r, blockchain)
+		case http.MethodPost:
+			handshakeREST(w, r, blockchain)
+		}
 	}
+}
+
+func nodeGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	flashStr := ""
-	err = r.ParseForm()
+	err := r.ParseForm()
 	if err == nil {
 		flashStr = r.PostForm.Get("flash")
 	}
 
 	type viewData struct {
-		Title string
-		Flash string
+		Title         string
+		Flash         string
+		RejectedNodes []*bc.RejectedNode
 	}
 
 	p := &viewData{
-		Title: "Node",
-		Flash: flashStr,
+		Title:         "Node",
+		Flash:         flashStr,
+		RejectedNodes: blockchain.RejectedNodes,
 	}
 
-	err = t.ExecuteTemplate(w, "layout", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	renderer.Render(w, "node", p)
 }
 
-func nodePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+func nodePost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	err := r.ParseForm()
 	if err != nil {
@@ -73,34 +80,38 @@ func nodePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain)
 
 	host := r.PostForm.Get("host")
 	if host == "" {
-		RenderHTTPError(w, "'Host' field not found", http.StatusBadRequest)
+		renderer.RenderHTTPError(w, "'Host' field not found", http.StatusBadRequest)
 		return
 	}
 
 	portStr := r.PostForm.Get("port")
 	if portStr == "" {
-		RenderHTTPError(w, "'Port' field not found", http.StatusBadRequest)
+		renderer.RenderHTTPError(w, "'Port' field not found", http.StatusBadRequest)
 		return
 	}
 
 	port, err := strconv.ParseInt(portStr, 10, 64)
 	if err != nil {
-		RenderHTTPError(w, "Failed to convert port: "+err.Error(),
+		renderer.RenderHTTPError(w, "Failed to convert port: "+err.Error(),
 			http.StatusBadRequest)
 		return
 	}
 
 	node := bc.NewNode(host, int(port))
-	blockchain.AddNode(node)
 
 	flashMsg := fmt.Sprintf("New node added!")
+
+	err = blockchain.Connect(r.Context(), node)
+	if err != nil {
+		flashMsg = fmt.Sprintf("Node %s rejected: %v", node, err)
+	}
 	formData := url.Values{
 		"flash": {flashMsg},
 	}
 
 	req, err := http.NewRequest(http.MethodPost, "/node", strings.NewReader(formData.Encode()))
 	if err != nil {
-		RenderHTTPError(w, "failed to POST status: "+err.Error(),
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
 			http.StatusInternalServerError)
 		return
 	}
@@ -108,7 +119,7 @@ func nodePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
 
-	nodeGet(w, req, blockchain)
+	nodeGet(w, req, renderer, blockchain)
 }
 
 func connectNodeHandler(w http.ResponseWriter, r *http.Request, blockchain *blockchain.Blockchain) {
@@ -120,27 +131,38 @@ func connectNodeHandler(w http.ResponseWriter, r *http.Request, blockchain *bloc
 	var addRequest addNodeRequest
 	err := json.NewDecoder(r.Body).Decode(&addRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	rejected := make([]*bc.RejectedNode, 0)
+
 	for _, node := range addRequest.Nodes {
-		blockchain.AddNode(node)
+		err = blockchain.Connect(r.Context(), node)
+		if err != nil {
+			rejected = append(rejected, &bc.RejectedNode{
+				Node:   node,
+				Reason: err.Error(),
+				Time:   blockchain.Clock.Now(),
+			})
+		}
 	}
 
 	var resp = struct {
 		Message    string
 		TotalNodes int
 		Nodes      []*bc.Node
+		Rejected   []*bc.RejectedNode
 	}{
 		"Nodes added",
 		len(blockchain.Nodes),
 		blockchain.Nodes,
+		rejected,
 	}
 
 	respJSON, err := json.MarshalIndent(resp, "", "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -148,3 +170,66 @@ func connectNodeHandler(w http.ResponseWriter, r *http.Request, blockchain *bloc
 	w.WriteHeader(http.StatusCreated)
 	w.Write(respJSON)
 }
+
+func nodesREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var resp = struct {
+		TotalNodes int
+		Nodes      []*bc.Node
+	}{
+		len(blockchain.Nodes),
+		blockchain.Nodes,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+func getHandshakeREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	respJSON, err := json.MarshalIndent(blockchain.NewHandshake(), "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+func handshakeREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var handshake bc.Handshake
+	err := json.NewDecoder(r.Body).Decode(&handshake)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the node is reached at this host if it does not know its address
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteHost = ""
+	}
+
+	resp, err := blockchain.AcceptHandshake(r.Context(), &handshake, remoteHost)
+	if err != nil {
+		RenderJSONError(w, "incompatible node: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
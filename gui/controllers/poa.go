@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PoAHandler is the HTML endpoint to see the validators and vote
+func PoAHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			poaGet(w, r, renderer, blockchain)
+		case http.MethodPost:
+			poaPost(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// VoteHandler is the REST endpoint to vote for adding or removing a validator
+func VoteHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			voteREST(w, r, blockchain)
+		}
+	}
+}
+
+func poaGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	flashStr := ""
+	err := r.ParseForm()
+	if err == nil {
+		flashStr = r.PostForm.Get("flash")
+	}
+
+	type viewData struct {
+		Title          string
+		Flash          string
+		Enabled        bool
+		PubKey         string
+		Validators     []string
+		ExpectedSigner string
+		NextIndex      int
+		Epoch          int
+	}
+
+	p := &viewData{
+		Title:     "Proof of authority",
+		Flash:     flashStr,
+		NextIndex: blockchain.GetPreviousBlock().Index + 1,
+	}
+
+	poa, ok := blockchain.Consensus.(*bc.ProofOfAuthority)
+	if ok {
+		p.Enabled = true
+		p.PubKey = poa.PubKey()
+
+		p.Validators, err = poa.ValidatorsAt(blockchain.Chain)
+		if err != nil {
+			renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		p.ExpectedSigner = p.Validators[p.NextIndex%len(p.Validators)]
+		p.Epoch = poa.EpochAt(blockchain.Chain)
+	}
+
+	renderer.Render(w, "poa", p)
+}
+
+func poaPost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	poa, ok := blockchain.Consensus.(*bc.ProofOfAuthority)
+	if !ok {
+		renderer.RenderHTTPError(w, "the node does not run the proof of authority",
+			http.StatusBadRequest)
+		return
+	}
+
+	candidate := r.PostForm.Get("candidate")
+	add := r.PostForm.Get("proposal") == "add"
+
+	tx, err := poa.NewVote(blockchain.Chain, candidate, add)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := blockchain.AddTransaction(tx)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flashMsg := fmt.Sprintf("Vote added to the pool. The vote should be "+
+		"added in block #%d", index)
+	formData := url.Values{
+		"flash": {flashMsg},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/poa", strings.NewReader(formData.Encode()))
+	if err != nil {
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+
+	poaGet(w, req, renderer, blockchain)
+}
+
+func voteREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	poa, ok := blockchain.Consensus.(*bc.ProofOfAuthority)
+	if !ok {
+		RenderJSONError(w, "the node does not run the proof of authority",
+			http.StatusBadRequest)
+		return
+	}
+
+	var voteRequest struct {
+		Candidate string
+		Add       bool
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&voteRequest)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := poa.NewVote(blockchain.Chain, voteRequest.Candidate,
+		voteRequest.Add)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := blockchain.AddTransaction(tx)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp = struct {
+		Message    string
+		BlockIndex int
+		Vote       *bc.Vote
+	}{
+		"Vote added",
+		index,
+		tx.Vote,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(respJSON)
+}
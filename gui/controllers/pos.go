@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoSHandler is the HTML endpoint to see the stakes and the slot leaders
+func PoSHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			posGet(w, r, renderer, blockchain)
+		case http.MethodPost:
+			posPost(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// SlashHandler is the REST endpoint to submit a double-signing evidence
+func SlashHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			slashREST(w, r, blockchain)
+		}
+	}
+}
+
+// upcomingSlots is the number of slot leaders displayed
+const upcomingSlots = 10
+
+func posGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	flashStr := ""
+	err := r.ParseForm()
+	if err == nil {
+		flashStr = r.PostForm.Get("flash")
+	}
+
+	type stake struct {
+		Staker  string
+		Stake   int
+		Percent string
+	}
+
+	type slot struct {
+		Slot   int
+		Start  string
+		Leader string
+	}
+
+	type viewData struct {
+		Title          string
+		Flash          string
+		Enabled        bool
+		PubKey         string
+		SlotDuration   time.Duration
+		CurrentSlot    int
+		SecondsLeft    int64
+		Stakes         []stake
+		UpcomingLeader []slot
+	}
+
+	p := &viewData{
+		Title: "Proof of stake",
+		Flash: flashStr,
+	}
+
+	pos, ok := blockchain.Consensus.(*bc.ProofOfStake)
+	if ok {
+		now := blockchain.Clock.Now().UnixNano()
+
+		p.Enabled = true
+		p.PubKey = pos.PubKey()
+		p.SlotDuration = pos.SlotDuration
+		p.CurrentSlot = pos.SlotAt(now)
+		p.SecondsLeft = (int64(p.CurrentSlot+1)*int64(pos.SlotDuration) - now) /
+			int64(time.Second)
+
+		stakes := pos.StakesAt(blockchain.Chain)
+		total := 0
+		for _, s := range stakes {
+			total += s
+		}
+
+		for staker, s := range stakes {
+			p.Stakes = append(p.Stakes, stake{
+				Staker:  staker,
+				Stake:   s,
+				Percent: fmt.Sprintf("%.1f%%", float64(s)*100/float64(total)),
+			})
+		}
+
+		sort.Slice(p.Stakes, func(i, j int) bool {
+			return p.Stakes[i].Stake > p.Stakes[j].Stake
+		})
+
+		for i := 0; i < upcomingSlots; i++ {
+			leader, err := pos.LeaderAt(blockchain.Chain, p.CurrentSlot+i)
+			if err != nil {
+				renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			start := time.Unix(0, int64(p.CurrentSlot+i)*int64(pos.SlotDuration))
+
+			p.UpcomingLeader = append(p.UpcomingLeader, slot{
+				Slot:   p.CurrentSlot + i,
+				Start:  start.Format("15:04:05"),
+				Leader: leader,
+			})
+		}
+	}
+
+	renderer.Render(w, "pos", p)
+}
+
+func posPost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var evidence bc.SlashingEvidence
+
+	err = json.Unmarshal([]byte(r.PostForm.Get("blocka")), &evidence.BlockA)
+	if err != nil {
+		renderer.RenderHTTPError(w, "Failed to read block A: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	err = json.Unmarshal([]byte(r.PostForm.Get("blockb")), &evidence.BlockB)
+	if err != nil {
+		renderer.RenderHTTPError(w, "Failed to read block B: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	tx, err := bc.NewSlashingTransaction(&evidence, blockchain.Address)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := blockchain.AddTransaction(tx)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flashMsg := fmt.Sprintf("Evidence added to the pool. The stakeholder "+
+		"should be slashed in block #%d", index)
+	formData := url.Values{
+		"flash": {flashMsg},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/pos", strings.NewReader(formData.Encode()))
+	if err != nil {
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+
+	posGet(w, req, renderer, blockchain)
+}
+
+func slashREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var evidence bc.SlashingEvidence
+	err := json.NewDecoder(r.Body).Decode(&evidence)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := bc.NewSlashingTransaction(&evidence, blockchain.Address)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := blockchain.AddTransaction(tx)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp = struct {
+		Message    string
+		BlockIndex int
+		Offender   string
+	}{
+		"Evidence added",
+		index,
+		evidence.BlockA.Signer,
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(respJSON)
+}
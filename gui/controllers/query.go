@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	bc "dummy-blockchain/blockchain"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// defaultLimit is the page size when the limit is not given
+	defaultLimit = 20
+	// maxLimit is the biggest page size
+	maxLimit = 100
+
+	blockCursor = "block:"
+	txCursor    = "tx:"
+)
+
+// blocksREST returns the blocks from "from" to "to", both included, by pages
+// of "limit" blocks. The next page is fetched with the "cursor" of the
+// response.
+func blocksREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	query := r.URL.Query()
+
+	from, err := intParam(query.Get("from"), 0)
+	if err != nil {
+		RenderJSONError(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := intParam(query.Get("to"), len(blockchain.Chain)-1)
+	if err != nil {
+		RenderJSONError(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := limitParam(query.Get("limit"))
+	if err != nil {
+		RenderJSONError(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cursor := query.Get("cursor")
+	if cursor != "" {
+		next, err := decodeCursor(cursor, blockCursor)
+		if err != nil {
+			RenderJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		from, err = strconv.Atoi(next)
+		if err != nil {
+			RenderJSONError(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if from < 0 || to < from {
+		RenderJSONError(w, "from must be positive and not after to",
+			http.StatusBadRequest)
+		return
+	}
+
+	if to >= len(blockchain.Chain) {
+		to = len(blockchain.Chain) - 1
+	}
+
+	blocks := make([]*bc.Block, 0, limit)
+	for i := from; i <= to && len(blocks) < limit; i++ {
+		blocks = append(blocks, blockchain.Chain[i])
+	}
+
+	var resp = struct {
+		Blocks     []*bc.Block
+		NextCursor string `json:",omitempty"`
+	}{
+		Blocks: blocks,
+	}
+
+	next := from + len(blocks)
+	if next <= to {
+		resp.NextCursor = encodeCursor(blockCursor + strconv.Itoa(next))
+	}
+
+	renderJSONWithETag(w, r, resp)
+}
+
+// latestBlockREST returns the last block of the chain
+func latestBlockREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var resp = struct {
+		Block *bc.Block
+	}{
+		blockchain.GetPreviousBlock(),
+	}
+
+	renderJSONWithETag(w, r, resp)
+}
+
+// transactionsREST returns the transactions of the chain, then the pending
+// ones, selected by sender, receiver, min_amount and max_amount, by pages of
+// "limit" transactions. The next page is fetched with the "cursor" of the
+// response.
+func transactionsREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	query := r.URL.Query()
+
+	filter := bc.TransactionFilter{
+		Sender:   query.Get("sender"),
+		Receiver: query.Get("receiver"),
+	}
+
+	if query.Get("min_amount") != "" {
+		minAmount, err := strconv.Atoi(query.Get("min_amount"))
+		if err != nil {
+			RenderJSONError(w, "invalid min_amount: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.MinAmount = &minAmount
+	}
+
+	if query.Get("max_amount") != "" {
+		maxAmount, err := strconv.Atoi(query.Get("max_amount"))
+		if err != nil {
+			RenderJSONError(w, "invalid max_amount: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.MaxAmount = &maxAmount
+	}
+
+	limit, err := limitParam(query.Get("limit"))
+	if err != nil {
+		RenderJSONError(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var after *bc.TxCursor
+
+	cursor := query.Get("cursor")
+	if cursor != "" {
+		last, err := decodeCursor(cursor, txCursor)
+		if err != nil {
+			RenderJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		after, err = parseTxCursor(last)
+		if err != nil {
+			RenderJSONError(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	locations, more, err := blockchain.QueryTransactions(filter, after, limit)
+	if err != nil {
+		// the transaction of the cursor disappeared, ie. after a reorg
+		RenderJSONError(w, "cursor expired: "+err.Error(), http.StatusGone)
+		return
+	}
+
+	type txResult struct {
+		ID          bc.Hash
+		BlockIndex  *int
+		Transaction *bc.Transaction
+	}
+
+	txs := make([]txResult, len(locations))
+	for i, loc := range locations {
+		txs[i] = txResult{ID: loc.ID, Transaction: loc.Tx}
+		if loc.Block != nil {
+			txs[i].BlockIndex = &loc.Block.Index
+		}
+	}
+
+	var resp = struct {
+		Transactions []txResult
+		NextCursor   string `json:",omitempty"`
+	}{
+		Transactions: txs,
+	}
+
+	if more {
+		last := blockchain.CursorOf(locations[len(locations)-1])
+		resp.NextCursor = encodeCursor(fmt.Sprintf("%s%d:%d:%s", txCursor,
+			last.Block, last.Index, last.ID))
+	}
+
+	renderJSONWithETag(w, r, resp)
+}
+
+// renderJSONWithETag writes the response with an ETag, or only a 304 if the
+// client already has it.
+func renderJSONWithETag(w http.ResponseWriter, r *http.Request, resp interface{}) {
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h := sha256.Sum256(respJSON)
+	etag := `"` + hex.EncodeToString(h[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+// encodeCursor makes an opaque cursor, so that clients do not rely on its
+// content.
+func encodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// decodeCursor returns the position stored in a cursor of the given kind
+func decodeCursor(cursor, kind string) (string, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(buf), kind) {
+		return "", xerrors.Errorf("invalid cursor")
+	}
+
+	return strings.TrimPrefix(string(buf), kind), nil
+}
+
+// parseTxCursor reads the position of a transaction cursor, as
+// <block>:<index>:<id>
+func parseTxCursor(position string) (*bc.TxCursor, error) {
+	parts := strings.Split(position, ":")
+	if len(parts) != 3 {
+		return nil, xerrors.Errorf("invalid position '%s'", position)
+	}
+
+	block, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, xerrors.Errorf("invalid block: %v", err)
+	}
+
+	index, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, xerrors.Errorf("invalid index: %v", err)
+	}
+
+	id, err := bc.ParseHash(parts[2])
+	if err != nil {
+		return nil, xerrors.Errorf("invalid id: %v", err)
+	}
+
+	return &bc.TxCursor{Block: block, Index: index, ID: id}, nil
+}
+
+func intParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func limitParam(value string) (int, error) {
+	limit, err := intParam(value, defaultLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > maxLimit {
+		return 0, xerrors.Errorf("must be between 1 and %d", maxLimit)
+	}
+
+	return limit, nil
+}
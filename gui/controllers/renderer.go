@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// NewRenderer parses every page of the views once. Each page is parsed with
+// the layout and the partials, views must be rooted at "views/".
+func NewRenderer(views fs.FS) (*Renderer, error) {
+	files, err := fs.Glob(views, "views/*.gohtml")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to list views: %v", err)
+	}
+
+	pages := make(map[string]*template.Template)
+
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".gohtml")
+
+		switch name {
+		case "layout", "partials":
+			continue
+		case "error":
+			pages[name], err = template.ParseFS(views, file)
+		default:
+			pages[name], err = template.ParseFS(views, "views/layout.gohtml",
+				file, "views/partials.gohtml")
+		}
+
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse view %s: %v", name, err)
+		}
+	}
+
+	if pages["error"] == nil {
+		return nil, xerrors.Errorf("error view not found")
+	}
+
+	return &Renderer{pages: pages}, nil
+}
+
+// Renderer renders the pages parsed at startup. It is safe for concurrent use.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+// Render renders a page inside the layout. The page is executed in a buffer
+// first so that a failing template does not send a half-written page.
+func (r *Renderer) Render(w http.ResponseWriter, page string, data interface{}) {
+	t, found := r.pages[page]
+	if !found {
+		r.RenderHTTPError(w, "view not found: "+page, http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	err := t.ExecuteTemplate(&buf, "layout", data)
+	if err != nil {
+		r.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// RenderHTTPError renders a user-friendly error
+func (r *Renderer) RenderHTTPError(w http.ResponseWriter, message string, code int) {
+
+	var viewData = struct {
+		Title   string
+		Message string
+		Code    int
+	}{
+		"Something bad happened",
+		message,
+		code,
+	}
+
+	var buf bytes.Buffer
+
+	err := r.pages["error"].ExecuteTemplate(&buf, "error", viewData)
+	if err != nil {
+		http.Error(w, message, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
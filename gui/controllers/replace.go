@@ -7,17 +7,16 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"text/template"
 )
 
 // ReplaceHandler is HTTP form handler
-func ReplaceHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+func ReplaceHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			replaceGet(w, r, blockchain)
+			replaceGet(w, r, renderer, blockchain)
 		case http.MethodPost:
-			replacePost(w, r, blockchain)
+			replacePost(w, r, renderer, blockchain)
 		}
 	}
 }
@@ -32,16 +31,10 @@ func ReplaceChainHandler(blockchain *bc.Blockchain) http.HandlerFunc {
 	}
 }
 
-func replaceGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
-
-	t, err := template.ParseFiles("gui/views/layout.gohtml", "gui/views/replace.gohtml")
-	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+func replaceGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	flashStr := ""
-	err = r.ParseForm()
+	err := r.ParseForm()
 	if err == nil {
 		flashStr = r.PostForm.Get("flash")
 	}
@@ -56,15 +49,12 @@ func replaceGet(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchai
 		Flash: flashStr,
 	}
 
-	err = t.ExecuteTemplate(w, "layout", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	renderer.Render(w, "replace", p)
 }
 
-func replacePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+func replacePost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
-	replaced, err := blockchain.ReplaceChain()
+	replaced, err := blockchain.ReplaceChain(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -83,7 +73,7 @@ func replacePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockcha
 
 	req, err := http.NewRequest(http.MethodPost, "/replace", strings.NewReader(formData.Encode()))
 	if err != nil {
-		RenderHTTPError(w, "failed to POST status: "+err.Error(),
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
 			http.StatusInternalServerError)
 		return
 	}
@@ -91,14 +81,14 @@ func replacePost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockcha
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
 
-	replaceGet(w, req, blockchain)
+	replaceGet(w, req, renderer, blockchain)
 }
 
 func replaceChainREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
 
-	replaced, err := blockchain.ReplaceChain()
+	replaced, err := blockchain.ReplaceChain(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -114,7 +104,7 @@ func replaceChainREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blo
 
 	respJSON, err := json.MarshalIndent(resp, "", "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ScriptHandler is the HTML endpoint to debug scripts
+func ScriptHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			scriptGet(w, r, renderer, blockchain)
+		case http.MethodPost:
+			scriptPost(w, r, renderer, blockchain)
+		}
+	}
+}
+
+// TraceScriptHandler is the REST endpoint to trace the execution of scripts
+func TraceScriptHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			traceScriptREST(w, r, blockchain)
+		}
+	}
+}
+
+type scriptViewData struct {
+	Title        string
+	UnlockScript string
+	LockScript   string
+	TxID         string
+	Height       int
+	Time         int64
+	Executed     bool
+	Error        string
+	Steps        []bc.TraceStep
+}
+
+func scriptGet(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	p := &scriptViewData{
+		Title:  "Script",
+		Height: blockchain.GetPreviousBlock().Index + 1,
+		Time:   blockchain.Clock.Now().Unix(),
+	}
+
+	renderScript(w, renderer, p)
+}
+
+func scriptPost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
+
+	err := r.ParseForm()
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p := &scriptViewData{
+		Title:        "Script",
+		UnlockScript: r.PostForm.Get("unlock"),
+		LockScript:   r.PostForm.Get("lock"),
+		TxID:         r.PostForm.Get("txid"),
+		Executed:     true,
+	}
+
+	p.Height, err = strconv.Atoi(r.PostForm.Get("height"))
+	if err != nil {
+		renderer.RenderHTTPError(w, "Failed to convert height: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	p.Time, err = strconv.ParseInt(r.PostForm.Get("time"), 10, 64)
+	if err != nil {
+		renderer.RenderHTTPError(w, "Failed to convert time: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	ctx := bc.ScriptContext{
+		Height:  p.Height,
+		Time:    p.Time,
+		ChainID: blockchain.Genesis.ChainID,
+	}
+
+	if p.TxID != "" {
+		id, err := bc.ParseHash(p.TxID)
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to read transaction ID: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		ctx.Tx = blockchain.FindTransaction(id)
+		if ctx.Tx == nil {
+			renderer.RenderHTTPError(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	p.Steps, err = bc.ExecuteScripts(bc.Script(p.UnlockScript),
+		bc.Script(p.LockScript), ctx)
+	if err != nil {
+		p.Error = err.Error()
+	}
+
+	renderScript(w, renderer, p)
+}
+
+func renderScript(w http.ResponseWriter, renderer *Renderer, p *scriptViewData) {
+
+	renderer.Render(w, "script", p)
+}
+
+func traceScriptREST(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+
+	var traceRequest struct {
+		UnlockScript bc.Script
+		LockScript   bc.Script
+		Transaction  *bc.Transaction
+		Height       int
+		Time         int64
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&traceRequest)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := bc.ScriptContext{
+		Tx:      traceRequest.Transaction,
+		Height:  traceRequest.Height,
+		Time:    traceRequest.Time,
+		ChainID: blockchain.Genesis.ChainID,
+	}
+
+	steps, err := bc.ExecuteScripts(traceRequest.UnlockScript,
+		traceRequest.LockScript, ctx)
+
+	var resp = struct {
+		Valid bool
+		Error string
+		Steps []bc.TraceStep
+	}{
+		Valid: err == nil,
+		Steps: steps,
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
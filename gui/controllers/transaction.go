@@ -2,23 +2,23 @@ package controllers
 
 import (
 	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/logs"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"text/template"
 )
 
 // TransactionHandler ...
-func TransactionHandler(blockchain *bc.Blockchain) http.HandlerFunc {
+func TransactionHandler(renderer *Renderer, blockchain *bc.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			TransactionNew(w, r, blockchain)
+			TransactionNew(w, r, renderer, blockchain)
 		case http.MethodPost:
-			TransactionPost(w, r, blockchain)
+			TransactionPost(w, r, renderer, blockchain)
 		}
 	}
 }
@@ -34,16 +34,10 @@ func AddTransactionHandler(blockchain *bc.Blockchain) http.HandlerFunc {
 }
 
 // TransactionNew ...
-func TransactionNew(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
-
-	t, err := template.ParseFiles("gui/views/layout.gohtml", "gui/views/transaction.gohtml")
-	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+func TransactionNew(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	flashStr := ""
-	err = r.ParseForm()
+	err := r.ParseForm()
 	if err == nil {
 		flashStr = r.PostForm.Get("flash")
 	}
@@ -60,50 +54,77 @@ func TransactionNew(w http.ResponseWriter, r *http.Request, blockchain *bc.Block
 		Flash: flashStr,
 	}
 
-	err = t.ExecuteTemplate(w, "layout", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	renderer.Render(w, "transaction", p)
 }
 
 // TransactionPost is called by the HTML form. It transforms the HTML arguments
 // into JSON and call the REST endpoint
-func TransactionPost(w http.ResponseWriter, r *http.Request, blockchain *bc.Blockchain) {
+func TransactionPost(w http.ResponseWriter, r *http.Request, renderer *Renderer, blockchain *bc.Blockchain) {
 
 	err := r.ParseForm()
 	if err != nil {
-		RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
+		renderer.RenderHTTPError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	sender := r.PostForm.Get("sender")
 	if sender == "" {
-		RenderHTTPError(w, "'Sender' field not found", http.StatusBadRequest)
+		renderer.RenderHTTPError(w, "'Sender' field not found", http.StatusBadRequest)
 		return
 	}
 
 	receiver := r.PostForm.Get("receiver")
 	if receiver == "" {
-		RenderHTTPError(w, "'Receiver' field not found", http.StatusBadRequest)
+		renderer.RenderHTTPError(w, "'Receiver' field not found", http.StatusBadRequest)
 		return
 	}
 
 	amountStr := r.PostForm.Get("amount")
 	if amountStr == "" {
-		RenderHTTPError(w, "'Amount' field not found", http.StatusBadRequest)
+		renderer.RenderHTTPError(w, "'Amount' field not found", http.StatusBadRequest)
 		return
 	}
 
 	amount, err := strconv.ParseInt(amountStr, 10, 64)
 	if err != nil {
-		RenderHTTPError(w, "Failed to convert amount: "+err.Error(),
+		renderer.RenderHTTPError(w, "Failed to convert amount: "+err.Error(),
 			http.StatusBadRequest)
 		return
 	}
 
 	transaction := bc.NewTransaction(sender, receiver, int(amount))
+	transaction.LockScript = bc.Script(r.PostForm.Get("lock"))
+
+	lockTimeStr := r.PostForm.Get("locktime")
+	if lockTimeStr != "" {
+		transaction.LockTime, err = strconv.ParseInt(lockTimeStr, 10, 64)
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to convert lock time: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+	}
 
-	index := blockchain.AddTransaction(transaction)
+	prevTxStr := r.PostForm.Get("prevtx")
+	if prevTxStr != "" {
+		prevTx, err := bc.ParseHash(prevTxStr)
+		if err != nil {
+			renderer.RenderHTTPError(w, "Failed to read spent tx ID: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+
+		transaction.Inputs = []*bc.Input{{
+			PrevTx:       prevTx,
+			UnlockScript: bc.Script(r.PostForm.Get("unlock")),
+		}}
+	}
+
+	index, err := blockchain.AddTransaction(transaction)
+	if err != nil {
+		renderer.RenderHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	flashMsg := fmt.Sprintf("New transaction added to the pool. "+
 		"The transaction should be added in block #%d", index)
@@ -113,7 +134,7 @@ func TransactionPost(w http.ResponseWriter, r *http.Request, blockchain *bc.Bloc
 
 	req, err := http.NewRequest(http.MethodPost, "/transaction", strings.NewReader(formData.Encode()))
 	if err != nil {
-		RenderHTTPError(w, "failed to POST status: "+err.Error(),
+		renderer.RenderHTTPError(w, "failed to POST status: "+err.Error(),
 			http.StatusInternalServerError)
 		return
 	}
@@ -121,7 +142,7 @@ func TransactionPost(w http.ResponseWriter, r *http.Request, blockchain *bc.Bloc
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
 
-	TransactionNew(w, req, blockchain)
+	TransactionNew(w, req, renderer, blockchain)
 }
 
 // AddTransactionPost is called by REST request
@@ -130,12 +151,16 @@ func AddTransactionPost(w http.ResponseWriter, r *http.Request, blockchain *bc.B
 	var transaction bc.Transaction
 	err := json.NewDecoder(r.Body).Decode(&transaction)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		fmt.Println(err.Error())
+		logs.FromContext(r.Context()).Warn("invalid transaction", "error", err)
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	index := blockchain.AddTransaction(&transaction)
+	index, err := blockchain.AddTransaction(&transaction)
+	if err != nil {
+		RenderJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var resp = struct {
 		Message    string
@@ -147,7 +172,7 @@ func AddTransactionPost(w http.ResponseWriter, r *http.Request, blockchain *bc.B
 
 	respJSON, err := json.MarshalIndent(resp, "", "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,15 @@
+// Package gui holds the views and the assets of the web interface. They are
+// embedded in the binary so that it can run from any working directory.
+package gui
+
+import "embed"
+
+// Views contains the html templates, under "views/"
+//
+//go:embed views
+var Views embed.FS
+
+// Assets contains the stylesheets and the images, under "assets/"
+//
+//go:embed assets
+var Assets embed.FS
@@ -0,0 +1,116 @@
+// Package logs provides the structured logger of the node. The logger travels
+// in the context of a request, along with the ID of the request, so that the
+// blockchain layer and the calls to the other nodes log with the same request
+// ID as the HTTP layer.
+package logs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"golang.org/x/xerrors"
+)
+
+// RequestIDHeader carries the ID of a request, from the clients and to the
+// other nodes.
+const RequestIDHeader = "X-Request-Id"
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// New returns a logger writing to w, as "json" or "text", from the given level
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		// slog prints the errors with %+v, which adds the frames of xerrors
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			err, ok := attr.Value.Any().(error)
+			if ok {
+				return slog.String(attr.Key, err.Error())
+			}
+			return attr
+		},
+	}
+
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, xerrors.Errorf("unknown log format '%s'", format)
+	}
+}
+
+// ParseLevel parses a level: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(s))
+	if err != nil {
+		return 0, xerrors.Errorf("failed to parse level: %v", err)
+	}
+
+	return level, nil
+}
+
+// WithLogger returns a context carrying the logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger of the context, or the default one
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// WithRequestID returns a context carrying the request ID. The logger of the
+// context adds it to every record.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	return WithLogger(ctx, FromContext(ctx).With("request_id", requestID))
+}
+
+// RequestID returns the request ID of the context, or "" if there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// NewTransport returns a transport that forwards the request ID of the
+// context to the other nodes.
+func NewTransport(next http.RoundTripper) http.RoundTripper {
+	return &transport{next: next}
+}
+
+// transport sets the request ID header on the outbound requests
+//
+// - implements http.RoundTripper
+type transport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestID(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,301 @@
+// Package metrics exposes the metrics of a node in the Prometheus text format.
+// It supports counters, gauges and histograms, with labels.
+//
+// See https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets for durations in seconds
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Registry holds the metrics of a node
+type Registry struct {
+	sync.Mutex
+	families []family
+}
+
+// family is a metric and all its series
+type family interface {
+	write(buf *bytes.Buffer)
+}
+
+// Handler is the endpoint serving the metrics: /metrics
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.Lock()
+		families := append([]family{}, r.families...)
+		r.Unlock()
+
+		var buf bytes.Buffer
+		for _, f := range families {
+			f.write(&buf)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
+
+func (r *Registry) register(f family) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.families = append(r.families, f)
+}
+
+// NewCounter registers a counter. The labels are given in the same order to
+// Add.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labels)}
+	r.register(c)
+
+	return c
+}
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels)}
+	r.register(g)
+
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is read when the metrics are
+// collected.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{name: name, help: help, kind: "gauge", value: value})
+}
+
+// NewCounterFunc registers a counter whose value is read when the metrics are
+// collected.
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{name: name, help: help, kind: "counter", value: value})
+}
+
+// NewHistogram registers a histogram with the given upper bounds, sorted.
+func (r *Registry) NewHistogram(name, help string, buckets []float64,
+	labels ...string) *Histogram {
+
+	h := &Histogram{vec: newVec(name, help, "histogram", labels), buckets: buckets}
+	r.register(h)
+
+	return h
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	*vec
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a positive value to the series with the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.update(labelValues, func(s *series) {
+		s.value += v
+	})
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.writeValues(buf)
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	*vec
+}
+
+// Set sets the value of the series with the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) {
+		s.value = v
+	})
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.writeValues(buf)
+}
+
+// Histogram counts the observations in buckets
+type Histogram struct {
+	*vec
+	buckets []float64
+}
+
+// Observe adds an observation to the series with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.buckets))
+		}
+
+		for i, bound := range h.buckets {
+			if v <= bound {
+				s.counts[i]++
+			}
+		}
+
+		s.value += v
+		s.count++
+	})
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.writeHeader(buf)
+
+	for _, s := range h.sorted() {
+		for i, bound := range h.buckets {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name,
+				h.labels(s, "le", formatFloat(bound)), s.counts[i])
+		}
+
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name, h.labels(s, "le", "+Inf"), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", h.name, h.labels(s), formatFloat(s.value))
+		fmt.Fprintf(buf, "%s_count%s %d\n", h.name, h.labels(s), s.count)
+	}
+}
+
+// vec holds the series of a metric, by label values
+type vec struct {
+	sync.Mutex
+
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	count       uint64
+	counts      []uint64
+}
+
+func newVec(name, help, kind string, labelNames []string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+}
+
+func (v *vec) update(labelValues []string, update func(*series)) {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s needs %d label(s), got %d", v.name,
+			len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.Lock()
+	defer v.Unlock()
+
+	s, found := v.series[key]
+	if !found {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		v.series[key] = s
+	}
+
+	update(s)
+}
+
+// sorted returns a copy of the series, sorted by label values so that the
+// output is stable.
+func (v *vec) sorted() []series {
+	v.Lock()
+	defer v.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]series, len(keys))
+	for i, key := range keys {
+		s := *v.series[key]
+		s.counts = append([]uint64{}, s.counts...)
+		result[i] = s
+	}
+
+	return result
+}
+
+func (v *vec) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+func (v *vec) writeValues(buf *bytes.Buffer) {
+	v.writeHeader(buf)
+
+	for _, s := range v.sorted() {
+		fmt.Fprintf(buf, "%s%s %s\n", v.name, v.labels(s), formatFloat(s.value))
+	}
+}
+
+// labels formats the labels of a series, with extra name/value pairs
+func (v *vec) labels(s series, extra ...string) string {
+	pairs := make([]string, 0, len(s.labelValues)+len(extra)/2)
+
+	for i, name := range v.labelNames {
+		pairs = append(pairs, name+"="+strconv.Quote(s.labelValues[i]))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// funcMetric is a metric without label whose value is read on collection
+type funcMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+func (f *funcMetric) write(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.kind)
+	fmt.Fprintf(buf, "%s %s\n", f.name, formatFloat(f.value()))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
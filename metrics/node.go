@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	bc "dummy-blockchain/blockchain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// hashStats is implemented by the consensus engines that compute hashes, ie.
+// the proof of work.
+type hashStats interface {
+	HashStats() (uint64, float64)
+}
+
+// RegisterNode registers the metrics of the blockchain: chain height, mempool
+// size, hash rate, mined blocks, reorgs and peers. It returns the transport to
+// use to contact the peers, which measures their latency.
+func RegisterNode(r *Registry, blockchain *bc.Blockchain,
+	next http.RoundTripper) http.RoundTripper {
+
+	r.NewGaugeFunc("dummy_chain_height", "Number of blocks in the chain.",
+		func() float64 {
+			return float64(len(blockchain.Snapshot().Chain))
+		})
+
+	r.NewGaugeFunc("dummy_mempool_size", "Number of pending transactions.",
+		func() float64 {
+			return float64(len(blockchain.Snapshot().Transactions))
+		})
+
+	r.NewGaugeFunc("dummy_peers", "Number of known nodes.", func() float64 {
+		return float64(len(blockchain.Snapshot().Nodes))
+	})
+
+	stats, ok := blockchain.Consensus.(hashStats)
+	if ok {
+		r.NewCounterFunc("dummy_hashes_total", "Hashes computed to mine blocks.",
+			func() float64 {
+				hashes, _ := stats.HashStats()
+				return float64(hashes)
+			})
+
+		r.NewGaugeFunc("dummy_hash_rate", "Hashes per second while mining the "+
+			"last block.", func() float64 {
+			_, rate := stats.HashStats()
+			return rate
+		})
+	}
+
+	blocksMined := r.NewCounter("dummy_blocks_mined_total",
+		"Blocks appended to the chain by this node.")
+	txsAdded := r.NewCounter("dummy_transactions_added_total",
+		"Transactions added to the pending transactions.")
+	reorgs := r.NewCounter("dummy_reorgs_total",
+		"Times the chain was replaced by the one of another node.")
+	reorgDepth := r.NewGauge("dummy_last_reorg_depth",
+		"Number of blocks dropped by the last replacement of the chain.")
+
+	// a subscriber would miss events during bursts, and undercount
+	blockchain.Events.Handle(func(event bc.Event) {
+		switch event.Type {
+		case bc.EventBlockAdded:
+			blocksMined.Inc()
+		case bc.EventTxAdded:
+			txsAdded.Inc()
+		case bc.EventReorg:
+			reorg := event.Data.(bc.Reorg)
+			reorgs.Inc()
+			reorgDepth.Set(float64(reorg.OldHeight - reorg.Fork))
+		}
+	})
+
+	latency := r.NewHistogram("dummy_peer_request_duration_seconds",
+		"Duration of the requests to the other nodes.", DefaultBuckets, "peer")
+	failures := r.NewCounter("dummy_peer_request_failures_total",
+		"Requests to the other nodes that failed.", "peer")
+
+	return &peerTransport{next: next, latency: latency, failures: failures}
+}
+
+// peerTransport measures the latency of the requests to the peers
+//
+// - implements http.RoundTripper
+type peerTransport struct {
+	next     http.RoundTripper
+	latency  *Histogram
+	failures *Counter
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *peerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.failures.Inc(req.URL.Host)
+		return nil, err
+	}
+
+	t.latency.Observe(time.Since(start).Seconds(), req.URL.Host)
+
+	return resp, nil
+}
+
+// NewHTTPMetrics registers the metrics of the HTTP server
+func NewHTTPMetrics(r *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: r.NewCounter("dummy_http_requests_total",
+			"HTTP requests handled, by route.", "route", "method", "code"),
+		duration: r.NewHistogram("dummy_http_request_duration_seconds",
+			"Duration of the HTTP requests, by route.", DefaultBuckets, "route"),
+	}
+}
+
+// HTTPMetrics counts and times the HTTP requests
+type HTTPMetrics struct {
+	requests *Counter
+	duration *Histogram
+}
+
+// Observe records a request
+func (m *HTTPMetrics) Observe(route, method string, code int, elapsed time.Duration) {
+	m.requests.Inc(route, method, strconv.Itoa(code))
+	m.duration.Observe(elapsed.Seconds(), route)
+}
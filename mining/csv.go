@@ -0,0 +1,48 @@
+package mining
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"golang.org/x/xerrors"
+)
+
+// csvHeader is the first row of the CSV export
+var csvHeader = []string{"strategy", "alpha", "gamma", "rounds", "pool_blocks",
+	"honest_blocks", "orphaned", "revenue_share", "expected"}
+
+// WriteCSV writes the results as CSV, one row per simulation
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(csvHeader)
+	if err != nil {
+		return xerrors.Errorf("failed to write header: %v", err)
+	}
+
+	float := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 6, 64)
+	}
+
+	for _, result := range results {
+		err = writer.Write([]string{
+			result.Strategy,
+			float(result.Alpha),
+			float(result.Gamma),
+			strconv.Itoa(result.Rounds),
+			strconv.Itoa(result.PoolBlocks),
+			strconv.Itoa(result.HonestBlocks),
+			strconv.Itoa(result.Orphaned),
+			float(result.RevenueShare),
+			float(result.Expected),
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to write result: %v", err)
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
@@ -0,0 +1,254 @@
+// Package mining simulates the race between a mining pool and the honest
+// miners, to compare the revenue of mining strategies. The proof of work is
+// abstracted away: each round, the next block is found by the pool with a
+// probability equal to its share of the hash power, and by the honest miners
+// otherwise.
+//
+// See "Majority is not Enough: Bitcoin Mining is Vulnerable", Eyal and Sirer,
+// https://arxiv.org/abs/1311.0243
+package mining
+
+import (
+	"math/rand"
+
+	"golang.org/x/xerrors"
+)
+
+// Miner is who found a block
+type Miner int
+
+const (
+	// Pool is the miner following the strategy
+	Pool Miner = iota
+	// Others are the honest miners, the rest of the network
+	Others
+)
+
+// Strategy decides when the pool publishes the blocks it finds. It is called
+// after each block found while the pool and the honest miners compete, and
+// publishes with Race.Publish.
+type Strategy interface {
+	// Name returns the name of the strategy
+	Name() string
+	// PoolMined is called when the pool found a block on its branch
+	PoolMined(race *Race)
+	// HonestMined is called when the honest miners found a block on the
+	// public branch, and the pool branch is not shorter.
+	HonestMined(race *Race)
+}
+
+// NewRace returns a race where the pool follows the strategy. gamma is the
+// share of the honest miners that mine on the pool block when two branches of
+// the same length are published.
+func NewRace(strategy Strategy, gamma float64, rand *rand.Rand) *Race {
+	return &Race{
+		strategy: strategy,
+		gamma:    gamma,
+		rand:     rand,
+		rewards:  make(map[Miner]int),
+	}
+}
+
+// Race is the state of the chain since the last block everybody agrees on:
+// the branch of the pool, partly published, and the public branch of the
+// honest miners.
+type Race struct {
+	strategy Strategy
+	gamma    float64
+	rand     *rand.Rand
+
+	pool      []Miner
+	public    []Miner
+	published int
+
+	rewards  map[Miner]int
+	orphaned int
+}
+
+// Private returns the length of the pool branch, published or not
+func (r *Race) Private() int {
+	return len(r.pool)
+}
+
+// Public returns the length of the honest branch
+func (r *Race) Public() int {
+	return len(r.public)
+}
+
+// Published returns the number of published blocks of the pool branch
+func (r *Race) Published() int {
+	return r.published
+}
+
+// Lead returns by how many blocks the pool branch is longer
+func (r *Race) Lead() int {
+	return len(r.pool) - len(r.public)
+}
+
+// Tie tells if both branches are fully published with the same length, so
+// that the honest miners are split between them.
+func (r *Race) Tie() bool {
+	return r.published > 0 && r.published == len(r.pool) &&
+		r.published == len(r.public)
+}
+
+// Publish publishes the first n blocks of the pool branch. If they are longer
+// than the public branch, the honest miners adopt them.
+func (r *Race) Publish(n int) {
+	if n > len(r.pool) {
+		n = len(r.pool)
+	}
+
+	if n > r.published {
+		r.published = n
+	}
+
+	if r.published > len(r.public) {
+		r.settle(r.pool[:r.published], len(r.public))
+		r.pool = r.pool[r.published:]
+		r.public = nil
+		r.published = 0
+	}
+}
+
+// PublishAll publishes the whole pool branch
+func (r *Race) PublishAll() {
+	r.Publish(len(r.pool))
+}
+
+// Rewards returns the number of blocks of each miner in the agreed chain
+func (r *Race) Rewards(miner Miner) int {
+	return r.rewards[miner]
+}
+
+// Orphaned returns the number of blocks dropped from a losing branch
+func (r *Race) Orphaned() int {
+	return r.orphaned
+}
+
+// Mine adds the next block, found by the given miner
+func (r *Race) Mine(miner Miner) {
+	if miner == Pool {
+		r.pool = append(r.pool, Pool)
+		r.strategy.PoolMined(r)
+		return
+	}
+
+	// some honest miners mine on the pool block during a tie, and adopt its
+	// branch when they find a block on it.
+	if r.Tie() && r.rand.Float64() < r.gamma {
+		r.settle(append(r.pool, Others), len(r.public))
+		r.pool = nil
+		r.public = nil
+		r.published = 0
+		return
+	}
+
+	r.public = append(r.public, Others)
+
+	if len(r.public) > len(r.pool) {
+		// the pool has nothing to compete with: it adopts the public branch
+		r.settle(r.public, len(r.pool))
+		r.pool = nil
+		r.public = nil
+		r.published = 0
+		return
+	}
+
+	r.strategy.HonestMined(r)
+}
+
+func (r *Race) settle(winner []Miner, orphaned int) {
+	for _, miner := range winner {
+		r.rewards[miner]++
+	}
+
+	r.orphaned += orphaned
+}
+
+// Config is the configuration of a simulation
+type Config struct {
+	// Alpha is the share of the hash power of the pool
+	Alpha float64
+	// Gamma is the share of the honest miners that mine on the pool block
+	// during a tie
+	Gamma  float64
+	Rounds int
+	Seed   int64
+}
+
+// Result is the outcome of a simulation
+type Result struct {
+	Strategy string
+	Alpha    float64
+	Gamma    float64
+	Rounds   int
+	// PoolBlocks and HonestBlocks are the blocks of each in the agreed chain
+	PoolBlocks   int
+	HonestBlocks int
+	Orphaned     int
+	// RevenueShare is the share of the agreed blocks mined by the pool
+	RevenueShare float64
+	// Expected is the revenue share given by the Eyal-Sirer formula for the
+	// selfish mining, or alpha for the honest mining.
+	Expected float64
+}
+
+// Simulate runs a race of the given number of rounds
+func Simulate(strategy Strategy, config Config) (Result, error) {
+	if config.Alpha < 0 || config.Alpha > 1 || config.Gamma < 0 || config.Gamma > 1 {
+		return Result{}, xerrors.Errorf("alpha and gamma must be between 0 and 1")
+	}
+
+	if config.Rounds < 1 {
+		return Result{}, xerrors.Errorf("need at least one round")
+	}
+
+	random := rand.New(rand.NewSource(config.Seed))
+	race := NewRace(strategy, config.Gamma, random)
+
+	for i := 0; i < config.Rounds; i++ {
+		if random.Float64() < config.Alpha {
+			race.Mine(Pool)
+		} else {
+			race.Mine(Others)
+		}
+	}
+
+	result := Result{
+		Strategy:     strategy.Name(),
+		Alpha:        config.Alpha,
+		Gamma:        config.Gamma,
+		Rounds:       config.Rounds,
+		PoolBlocks:   race.Rewards(Pool),
+		HonestBlocks: race.Rewards(Others),
+		Orphaned:     race.Orphaned(),
+		Expected:     config.Alpha,
+	}
+
+	total := result.PoolBlocks + result.HonestBlocks
+	if total > 0 {
+		result.RevenueShare = float64(result.PoolBlocks) / float64(total)
+	}
+
+	_, selfish := strategy.(Selfish)
+	if selfish {
+		result.Expected = SelfishRevenue(config.Alpha, config.Gamma)
+	}
+
+	return result, nil
+}
+
+// SelfishRevenue returns the expected revenue share of a selfish pool, as
+// given by the equation 8 of Eyal and Sirer.
+func SelfishRevenue(alpha, gamma float64) float64 {
+	if alpha >= 0.5 {
+		return 1
+	}
+
+	a := alpha
+	numerator := a*(1-a)*(1-a)*(4*a+gamma*(1-2*a)) - a*a*a
+	denominator := 1 - a*(1+(2-a)*a)
+
+	return numerator / denominator
+}
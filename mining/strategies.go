@@ -0,0 +1,50 @@
+package mining
+
+// Honest publishes each block as soon as it is found
+type Honest struct{}
+
+// Name implements Strategy. It returns "honest".
+func (Honest) Name() string {
+	return "honest"
+}
+
+// PoolMined implements Strategy. It publishes the block.
+func (Honest) PoolMined(race *Race) {
+	race.PublishAll()
+}
+
+// HonestMined implements Strategy. The pool has no block to compete with.
+func (Honest) HonestMined(race *Race) {}
+
+// Selfish keeps its blocks private and publishes them only to override the
+// blocks of the honest miners, following the state machine of Eyal and Sirer.
+type Selfish struct{}
+
+// Name implements Strategy. It returns "selfish".
+func (Selfish) Name() string {
+	return "selfish"
+}
+
+// PoolMined implements Strategy. During a tie, the pool publishes the block to
+// win the race. Otherwise it keeps the block private.
+func (Selfish) PoolMined(race *Race) {
+	tie := race.Lead() == 1 && race.Published() == race.Public() &&
+		race.Public() > 0
+
+	if tie {
+		race.PublishAll()
+	}
+}
+
+// HonestMined implements Strategy:
+//   - with no lead anymore, the pool publishes its branch to create a tie;
+//   - with a lead of one block, it publishes its branch, which wins;
+//   - otherwise, it publishes as many blocks as the honest miners found.
+func (Selfish) HonestMined(race *Race) {
+	switch race.Lead() {
+	case 0, 1:
+		race.PublishAll()
+	default:
+		race.Publish(race.Public())
+	}
+}
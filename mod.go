@@ -3,83 +3,197 @@ package main
 import (
 	"context"
 	"dummy-blockchain/blockchain"
-	"dummy-blockchain/gui/controllers"
-	"encoding/json"
+	"dummy-blockchain/logs"
+	"dummy-blockchain/mining"
+	"dummy-blockchain/server"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
-)
-
-type key int
-
-const (
-	requestIDKey key = 0
+	"golang.org/x/xerrors"
 )
 
 func main() {
+	// subcommands come before the flags
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var listenAddr string
 	flag.StringVar(&listenAddr, "listen-addr", ":8080", "server listen address")
+	var advertiseAddr string
+	flag.StringVar(&advertiseAddr, "advertise-addr", "", "host:port at which "+
+		"the other nodes reach the node, sent in the handshake. Defaults to the "+
+		"-listen-addr, on localhost if it has no host")
 	var ownerAddr string
 	flag.StringVar(&ownerAddr, "owner", "alice", "owner address to which the "+
 		"transaction fees are given")
+	var consensusName string
+	flag.StringVar(&consensusName, "consensus", "pow", "consensus engine: "+
+		"pow, poa, or pos")
+	var genesisPath string
+	flag.StringVar(&genesisPath, "genesis", "", "genesis JSON file, giving "+
+		"the chain ID, the consensus and the initial allocations. Replaces "+
+		"-consensus, -validators, -stakes and -slot-duration")
+	var validators string
+	flag.StringVar(&validators, "validators", "", "comma-separated list of "+
+		"the initial validators' public keys, for the proof of authority")
+	var stakes string
+	flag.StringVar(&stakes, "stakes", "", "comma-separated list of "+
+		"<public key>:<stake> initial stakes, for the proof of stake")
+	var slotDuration time.Duration
+	flag.DurationVar(&slotDuration, "slot-duration", 10*time.Second,
+		"duration of a slot, for the proof of stake")
+	var validatorKey string
+	flag.StringVar(&validatorKey, "validator-key", "", "private key of the "+
+		"node, if it is a validator or a stakeholder")
+	var keygen bool
+	flag.BoolVar(&keygen, "keygen", false, "print a new key pair and exit")
+	var attacker bool
+	flag.BoolVar(&attacker, "attacker", false, "enable the double-spend "+
+		"demonstration on /attack")
+	var adminToken string
+	flag.StringVar(&adminToken, "admin-token", "", "token required by the "+
+		"admin actions, ie. setting the faults or forcing an import. They are "+
+		"disabled without it")
+	var allowedOrigins string
+	flag.StringVar(&allowedOrigins, "allowed-origins", "", "comma-separated "+
+		"list of the origins, besides the node itself, whose pages can open a "+
+		"WebSocket on /rpc, ie. http://localhost:3000")
+	var seed int64
+	flag.Int64Var(&seed, "seed", 0, "seed of the random bytes, ie. for the "+
+		"node address and the keys, to reproduce a chain. 0 uses crypto/rand")
+	var simTime string
+	flag.StringVar(&simTime, "sim-time", "", "start the node with a simulated "+
+		"clock at this RFC 3339 time, instead of the system clock")
+	var simStep time.Duration
+	flag.DurationVar(&simStep, "sim-step", time.Second, "how much the "+
+		"simulated clock moves forward each time it is read")
+	var logFormat string
+	flag.StringVar(&logFormat, "log-format", "text", "log format: json or text")
+	var logLevel string
+	flag.StringVar(&logLevel, "log-level", "info", "minimum log level: debug, "+
+		"info, warn, or error")
 
 	flag.Parse()
 
-	address := uuid.New().String()
-	address = strings.ReplaceAll(address, "-", "")
-	blockchain := blockchain.NewBlockchain(address)
+	level, err := logs.ParseLevel(logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -log-level: %v\n", err)
+		os.Exit(2)
+	}
+
+	logger, err := logs.New(os.Stdout, logFormat, level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -log-format: %v\n", err)
+		os.Exit(2)
+	}
+
+	slog.SetDefault(logger)
 
-	logger := log.New(os.Stdout, "http: ", log.LstdFlags)
-	logger.Println("Server is starting...")
+	var entropy io.Reader
+	if seed != 0 {
+		entropy = blockchain.NewSeededEntropy(seed)
+	}
 
-	mux := http.NewServeMux()
+	var clock blockchain.Clock
+	if simTime != "" {
+		start, err := time.Parse(time.RFC3339, simTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -sim-time: %v\n", err)
+			os.Exit(2)
+		}
+		clock = blockchain.NewSimulatedClock(start, simStep)
+	}
+
+	if keygen {
+		pubKey, privKey, err := blockchain.NewKeyPair(entropy)
+		if err != nil {
+			fatal(logger, "Failed to generate key pair", err)
+		}
+		fmt.Printf("public key:  %s\nprivate key: %s\n", pubKey, privKey)
+		return
+	}
+
+	var genesis *blockchain.Genesis
+	if genesisPath != "" {
+		genesis, err = blockchain.LoadGenesis(genesisPath)
+	} else {
+		genesis, err = genesisFromFlags(consensusName, validators, stakes,
+			slotDuration)
+	}
+	if err != nil {
+		fatal(logger, "Failed to create the genesis", err)
+	}
 
-	fs := http.FileServer(http.Dir("./gui/assets"))
-	mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
+	consensus, err := genesis.NewConsensus(validatorKey)
+	if err != nil {
+		fatal(logger, "Failed to create consensus", err)
+	}
 
-	// HTML endpoint
-	mux.HandleFunc("/", controllers.HomeHandler(blockchain, ownerAddr))
-	// REST endpoint
-	mux.HandleFunc("/get_chain", controllers.GetChainHandler(blockchain))
+	address, err := blockchain.NewAddress(entropy)
+	if err != nil {
+		fatal(logger, "Failed to create the address", err)
+	}
 
-	// HTML endpoint
-	mux.HandleFunc("/transaction", controllers.TransactionHandler(blockchain))
-	// REST endpoint
-	mux.HandleFunc("/add_transaction", controllers.AddTransactionHandler(blockchain))
+	if advertiseAddr == "" {
+		advertiseAddr = listenAddr
+	}
 
-	// HTML endpoint
-	mux.HandleFunc("/mine", controllers.MineHandler(blockchain, ownerAddr))
-	// REST endpoint
-	mux.HandleFunc("/mine_block", controllers.MineRESTHandler(blockchain, ownerAddr))
+	lu := &url.URL{Scheme: "http"}
+	if strings.HasPrefix(advertiseAddr, ":") {
+		lu.Host = "localhost" + advertiseAddr
+	} else {
+		lu.Host = advertiseAddr
+	}
 
-	// HTML endpoint
-	mux.HandleFunc("/replace", controllers.ReplaceHandler(blockchain))
-	// REST endpoint
-	mux.HandleFunc("/replace_chain", controllers.ReplaceChainHandler(blockchain))
+	port, err := strconv.Atoi(lu.Port())
+	if err != nil {
+		fatal(logger, "Invalid listen or advertised address", err)
+	}
 
-	// HTML endpoint
-	mux.HandleFunc("/node", controllers.NodeHandler(blockchain))
-	// REST endpoint
-	mux.HandleFunc("/connect_node", controllers.ConnectNodesHandler(blockchain))
+	config := server.Config{
+		Address:    address,
+		Owner:      ownerAddr,
+		Genesis:    genesis,
+		Consensus:  consensus,
+		Logger:     logger,
+		Attacker:   attacker,
+		AdminToken: adminToken,
+		Clock:      clock,
+		Entropy:    entropy,
+		Listen:     blockchain.NewNode(lu.Hostname(), port),
+	}
 
-	mux.HandleFunc("/is_valid", isValidHandler(blockchain))
+	if allowedOrigins != "" {
+		config.AllowedOrigins = strings.Split(allowedOrigins, ",")
+	}
 
-	nextRequestID := func() string {
-		return fmt.Sprintf("%d", time.Now().UnixNano())
+	node, err := server.New(config)
+	if err != nil {
+		fatal(logger, "Failed to create the node", err)
 	}
 
-	server := &http.Server{
+	logger.Info("Server is starting...", "address", address,
+		"consensus", consensus.Name(), "chain_id", genesis.ChainID)
+
+	httpServer := &http.Server{
 		Addr:         listenAddr,
-		Handler:      tracing(nextRequestID)(logging(logger)(mux)),
-		ErrorLog:     logger,
+		Handler:      node.Handler,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		ReadTimeout:  50 * time.Second,
 		WriteTimeout: 600 * time.Second,
 		// IdleTimeout:  150 * time.Second,
@@ -91,96 +205,254 @@ func main() {
 
 	go func() {
 		<-quit
-		logger.Println("Server is shutting down...")
+		logger.Info("Server is shutting down...")
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		server.SetKeepAlivesEnabled(false)
-		if err := server.Shutdown(ctx); err != nil {
-			logger.Fatalf("Could not gracefully shutdown the server: %v\n", err)
+		httpServer.SetKeepAlivesEnabled(false)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			fatal(logger, "Could not gracefully shutdown the server", err)
 		}
 		close(done)
 	}()
 
-	lu := &url.URL{Scheme: "http"}
-	if strings.HasPrefix(listenAddr, ":") {
-		lu.Host = "localhost" + listenAddr
-	} else {
-		lu.Host = listenAddr
-	}
-
-	logger.Println("Server is ready to handle requests at", lu)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Fatalf("Could not listen on %s: %v\n", listenAddr, err)
+	logger.Info("Server is ready to handle requests", "url", lu.String())
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fatal(logger, "Could not listen on "+listenAddr, err)
 	}
 
 	<-done
-	logger.Println("Server stopped")
+	logger.Info("Server stopped")
 }
 
-func logging(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			defer func() {
-				requestID, ok := r.Context().Value(requestIDKey).(string)
-				if !ok {
-					requestID = "unknown"
-				}
-				logger.Println(requestID, r.Method, r.URL.Path, r.RemoteAddr,
-					r.UserAgent())
-			}()
-			next.ServeHTTP(w, r)
-		})
-	}
+// fatal logs the error and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-func tracing(nextRequestID func() string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestID := r.Header.Get("X-Request-Id")
-			if requestID == "" {
-				requestID = nextRequestID()
+// genesisFromFlags returns the default genesis with the consensus given by the
+// flags, so that the nodes started with the same flags share their genesis.
+func genesisFromFlags(name, validators, stakes string,
+	slotDuration time.Duration) (*blockchain.Genesis, error) {
+
+	genesis := blockchain.DefaultGenesis()
+	genesis.Consensus = name
+
+	switch name {
+	case "pow":
+	case "poa":
+		genesis.Validators = strings.Split(validators, ",")
+	case "pos":
+		initialStakes := make(map[string]int)
+		for _, s := range strings.Split(stakes, ",") {
+			parts := strings.Split(s, ":")
+			if len(parts) != 2 {
+				return nil, xerrors.Errorf("stake should be <public key>:<stake>: %s", s)
+			}
+
+			stake, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, xerrors.Errorf("failed to convert stake: %v", err)
 			}
-			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
-			w.Header().Set("X-Request-Id", requestID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+
+			initialStakes[parts[0]] = stake
+		}
+
+		genesis.Stakes = initialStakes
+		genesis.SlotDuration = slotDuration.String()
+	default:
+		return nil, xerrors.Errorf("unknown consensus '%s'", name)
 	}
+
+	return genesis, genesis.Validate()
 }
 
-func isValidHandler(blockchain *blockchain.Blockchain) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			http.Error(w, "only GET is allowed", http.StatusBadRequest)
-			return
+// runCommand runs the subcommand with the given name
+func runCommand(name string, args []string) error {
+	switch name {
+	case "selfish":
+		return selfishCommand(args)
+	case "export":
+		return exportCommand(args)
+	case "import":
+		return importCommand(args)
+	default:
+		return xerrors.Errorf("unknown command '%s'", name)
+	}
+}
+
+// selfishCommand simulates the selfish and honest mining for each hash share
+// and gamma, and writes the revenues as CSV.
+func selfishCommand(args []string) error {
+	flags := flag.NewFlagSet("selfish", flag.ExitOnError)
+	alphas := flags.String("alphas", "0.1,0.2,0.25,0.3,0.33,0.35,0.4,0.45",
+		"comma-separated list of hash shares of the pool")
+	gammas := flags.String("gammas", "0,0.5,1", "comma-separated list of "+
+		"shares of the honest miners mining on the pool block during a tie")
+	rounds := flags.Int("rounds", 100000, "number of blocks found per simulation")
+	seed := flags.Int64("seed", 1, "seed of the simulations")
+	out := flags.String("out", "", "CSV file to write, instead of the "+
+		"standard output")
+
+	flags.Parse(args)
+
+	alphaValues, err := parseFloats(*alphas)
+	if err != nil {
+		return xerrors.Errorf("invalid -alphas: %v", err)
+	}
+
+	gammaValues, err := parseFloats(*gammas)
+	if err != nil {
+		return xerrors.Errorf("invalid -gammas: %v", err)
+	}
+
+	results := make([]mining.Result, 0)
+
+	for _, gamma := range gammaValues {
+		for _, alpha := range alphaValues {
+			config := mining.Config{
+				Alpha:  alpha,
+				Gamma:  gamma,
+				Rounds: *rounds,
+				Seed:   *seed,
+			}
+
+			for _, strategy := range []mining.Strategy{mining.Honest{}, mining.Selfish{}} {
+				result, err := mining.Simulate(strategy, config)
+				if err != nil {
+					return xerrors.Errorf("failed to simulate: %v", err)
+				}
+
+				results = append(results, result)
+			}
 		}
+	}
 
-		isValid, err := blockchain.IsCHainValid(blockchain.Chain)
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return xerrors.Errorf("failed to create file: %v", err)
 		}
+		defer w.Close()
+	}
+
+	return mining.WriteCSV(w, results)
+}
+
+// exportCommand downloads the chain of a node to a file
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	node := flags.String("node", "http://localhost:8080", "url of the node")
+	format := flags.String("format", string(blockchain.JSONLines), "format of "+
+		"the file: jsonl or binary")
+	out := flags.String("out", "", "file to write, instead of the standard output")
+
+	flags.Parse(args)
 
-		var resp = struct {
-			IsValid bool
-		}{
-			isValid,
+	_, err := blockchain.ParseFormat(*format)
+	if err != nil {
+		return xerrors.Errorf("invalid -format: %v", err)
+	}
+
+	resp, err := http.Get(strings.TrimSuffix(*node, "/") + "/export?format=" +
+		url.QueryEscape(*format))
+	if err != nil {
+		return xerrors.Errorf("failed to get the chain: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("node answered %s", commandError(resp))
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			return xerrors.Errorf("failed to create file: %v", err)
 		}
+		defer w.Close()
+	}
 
-		respJSON, err := json.MarshalIndent(resp, "", "")
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to write the chain: %v", err)
+	}
+
+	return nil
+}
+
+// importCommand replaces the chain of a node by the one of a file, in any
+// format. The node validates it, and keeps its chain if it is preferred,
+// unless the import is forced with the admin token.
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	node := flags.String("node", "http://localhost:8080", "url of the node")
+	in := flags.String("in", "", "file to read, instead of the standard input")
+	force := flags.Bool("force", false, "replace the chain of the node even "+
+		"if it is preferred by the fork choice. Requires -admin-token")
+	adminToken := flags.String("admin-token", "", "admin token of the node")
+
+	flags.Parse(args)
+
+	r := os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return xerrors.Errorf("failed to open file: %v", err)
 		}
+		defer f.Close()
+		r = f
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(*node, "/")+
+		"/import?force="+strconv.FormatBool(*force), r)
+	if err != nil {
+		return xerrors.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if *adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+*adminToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return xerrors.Errorf("failed to send the chain: %v", err)
+	}
+	defer resp.Body.Close()
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(respJSON)
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("node answered %s", commandError(resp))
 	}
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	fmt.Println()
+
+	return err
 }
 
-func faviconHandler(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "assets/images/favicon.ico")
+// commandError returns the status and the error message of a response
+func commandError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.Status + ": " + strings.TrimSpace(string(body))
+}
+
+// parseFloats parses a comma-separated list of numbers
+func parseFloats(s string) ([]float64, error) {
+	values := make([]float64, 0)
+	for _, field := range strings.Split(s, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to convert '%s': %v", field, err)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
 }
 
 // To build for the main distros:
@@ -0,0 +1,294 @@
+// Package openapi holds the OpenAPI specification of the REST API. It checks
+// that the spec and the registered endpoints agree, and validates the request
+// bodies against the schemas of the spec.
+package openapi
+
+import (
+	"bytes"
+	_ "embed" // to embed the spec
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Raw is the OpenAPI document, as served to the clients
+//
+//go:embed openapi.json
+var Raw []byte
+
+// Load parses the embedded OpenAPI document
+func Load() (*Spec, error) {
+	var spec Spec
+
+	err := json.Unmarshal(Raw, &spec)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse spec: %v", err)
+	}
+
+	return &spec, nil
+}
+
+// Spec is the part of an OpenAPI 3 document we use
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is an endpoint for one method
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// RequestBody describes the body of an operation
+type RequestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Schema is the subset of JSON schema used by the spec
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	AllOf                []*Schema          `json:"allOf"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	Pattern              string             `json:"pattern"`
+}
+
+// Endpoint is a registered path and the methods it handles
+type Endpoint struct {
+	Path    string
+	Methods []string
+}
+
+// FieldError tells why a field of a request body is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// CheckEndpoints returns an error if an endpoint is not in the spec, or if
+// the spec describes an endpoint that is not registered.
+func (s *Spec) CheckEndpoints(endpoints []Endpoint) error {
+	registered := make(map[string]bool)
+	drift := make([]string, 0)
+
+	for _, endpoint := range endpoints {
+		for _, method := range endpoint.Methods {
+			method = strings.ToLower(method)
+			registered[method+" "+endpoint.Path] = true
+
+			if s.Paths[endpoint.Path][method] == nil {
+				drift = append(drift, fmt.Sprintf("%s %s is not in the spec",
+					strings.ToUpper(method), endpoint.Path))
+			}
+		}
+	}
+
+	for path, operations := range s.Paths {
+		for method := range operations {
+			if !registered[method+" "+path] {
+				drift = append(drift, fmt.Sprintf("%s %s is not registered",
+					strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return xerrors.Errorf("spec and endpoints differ: %s",
+			strings.Join(drift, ", "))
+	}
+
+	return nil
+}
+
+// BodySchema returns the schema of the JSON body of an endpoint, or nil if it
+// takes no body.
+func (s *Spec) BodySchema(path, method string) *Schema {
+	op := s.Paths[path][strings.ToLower(method)]
+	if op == nil || op.RequestBody == nil {
+		return nil
+	}
+
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// ValidateBody checks a JSON body against a schema. It returns an error if the
+// body is not JSON, and the invalid fields otherwise. Property names are
+// matched case-insensitively, like encoding/json does.
+func (s *Spec) ValidateBody(schema *Schema, body []byte) ([]FieldError, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid JSON: %v", err)
+	}
+
+	errs := make([]FieldError, 0)
+	s.validate(schema, value, "", &errs)
+
+	return errs, nil
+}
+
+func (s *Spec) validate(schema *Schema, value interface{}, field string, errs *[]FieldError) {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		name := field
+		if name == "" {
+			name = "(body)"
+		}
+
+		*errs = append(*errs, FieldError{Field: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !schema.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	for _, sub := range schema.AllOf {
+		s.validate(sub, value, field, errs)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		s.validateObject(schema, object, field, errs)
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range array {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if schema.MinLength != nil && len(str) < *schema.MinLength {
+			fail("must have at least %d character(s)", *schema.MinLength)
+		}
+		if schema.Pattern != "" {
+			matched, err := regexp.MatchString(schema.Pattern, str)
+			if err == nil && !matched {
+				fail("must match %s", schema.Pattern)
+			}
+		}
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		if schema.Type == "integer" {
+			_, err := strconv.ParseInt(number.String(), 10, 64)
+			if err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		f, _ := number.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			fail("must be at most %v", *schema.Maximum)
+		}
+
+	case "boolean":
+		_, ok := value.(bool)
+		if !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func (s *Spec) validateObject(schema *Schema, object map[string]interface{},
+	field string, errs *[]FieldError) {
+
+	prefix := field
+	if prefix != "" {
+		prefix += "."
+	}
+
+	// encoding/json matches the keys without case
+	keys := make(map[string]string, len(object))
+	for key := range object {
+		keys[strings.ToLower(key)] = key
+	}
+
+	for _, name := range schema.Required {
+		_, found := keys[strings.ToLower(name)]
+		if !found {
+			*errs = append(*errs, FieldError{Field: prefix + name, Message: "is required"})
+		}
+	}
+
+	// sorted, so that the errors are always in the same order
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key, found := keys[strings.ToLower(name)]
+		if found {
+			s.validate(schema.Properties[name], object[key], prefix+name, errs)
+		}
+	}
+
+	if schema.AdditionalProperties != nil && len(schema.Properties) == 0 {
+		for key, value := range object {
+			s.validate(schema.AdditionalProperties, value, prefix+key, errs)
+		}
+	}
+}
+
+// resolve follows the references to the schemas of the components
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		schema = s.Components.Schemas[name]
+	}
+
+	return schema
+}
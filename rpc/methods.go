@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"context"
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+)
+
+// BlockParams are the params of getBlock, which takes either an index or a
+// hash.
+type BlockParams struct {
+	Index *int
+	Hash  string
+}
+
+// TransactionParams are the params of getTransaction
+type TransactionParams struct {
+	ID string
+}
+
+// TransactionResult is the result of getTransaction. BlockIndex is nil for a
+// pending transaction.
+type TransactionResult struct {
+	Transaction   *bc.Transaction
+	BlockIndex    *int
+	Confirmations int
+}
+
+// BalanceParams are the params of getBalance
+type BalanceParams struct {
+	Address string
+}
+
+// SendTransactionResult is the result of sendTransaction
+type SendTransactionResult struct {
+	ID         bc.Hash
+	BlockIndex int
+}
+
+func (s *Server) getBlock(params json.RawMessage) (interface{}, *Error) {
+	var p BlockParams
+	rpcErr := decodeParams(params, &p)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	var block *bc.Block
+
+	switch {
+	case p.Index != nil:
+		block = s.blockchain.GetBlock(*p.Index)
+	case p.Hash != "":
+		hash, err := bc.ParseHash(p.Hash)
+		if err != nil {
+			return nil, newError(InvalidParams, "invalid hash: "+err.Error())
+		}
+		block = s.blockchain.FindBlock(hash)
+	default:
+		return nil, newError(InvalidParams, "Index or Hash is required")
+	}
+
+	if block == nil {
+		return nil, newError(NotFound, "block not found")
+	}
+
+	return block, nil
+}
+
+func (s *Server) getTransaction(params json.RawMessage) (interface{}, *Error) {
+	var p TransactionParams
+	rpcErr := decodeParams(params, &p)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	id, err := bc.ParseHash(p.ID)
+	if err != nil {
+		return nil, newError(InvalidParams, "invalid ID: "+err.Error())
+	}
+
+	loc := s.blockchain.LocateTransaction(id)
+	if loc == nil {
+		return nil, newError(NotFound, "transaction not found")
+	}
+
+	result := TransactionResult{
+		Transaction: loc.Tx,
+	}
+
+	if loc.Block != nil {
+		result.BlockIndex = &loc.Block.Index
+		result.Confirmations = len(s.blockchain.Snapshot().Chain) - loc.Block.Index
+	}
+
+	return result, nil
+}
+
+func (s *Server) getBalance(params json.RawMessage) (interface{}, *Error) {
+	var p BalanceParams
+	rpcErr := decodeParams(params, &p)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	if p.Address == "" {
+		return nil, newError(InvalidParams, "Address is required")
+	}
+
+	return s.blockchain.GetBalance(p.Address), nil
+}
+
+func (s *Server) sendTransaction(params json.RawMessage) (interface{}, *Error) {
+	var tx bc.Transaction
+	rpcErr := decodeParams(params, &tx)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	if tx.Sender == "" || tx.Receiver == "" {
+		return nil, newError(InvalidParams, "Sender and Receiver are required")
+	}
+
+	id, err := tx.ID()
+	if err != nil {
+		return nil, newError(InternalError, err.Error())
+	}
+
+	index, err := s.blockchain.AddTransaction(&tx)
+	if err != nil {
+		return nil, newError(Rejected, err.Error())
+	}
+
+	return SendTransactionResult{ID: id, BlockIndex: index}, nil
+}
+
+func (s *Server) mine(ctx context.Context) (interface{}, *Error) {
+	block, err := s.blockchain.MineBlock(ctx, s.me)
+	if err != nil {
+		return nil, newError(Rejected, err.Error())
+	}
+
+	return block, nil
+}
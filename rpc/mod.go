@@ -0,0 +1,283 @@
+// Package rpc implements a JSON-RPC 2.0 API to the blockchain, over HTTP POST
+// and over WebSocket. Only the WebSocket transport supports subscriptions.
+//
+// See https://www.jsonrpc.org/specification
+package rpc
+
+import (
+	"context"
+	bc "dummy-blockchain/blockchain"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Version is the JSON-RPC version we implement
+const Version = "2.0"
+
+// Error codes returned in the "error" member of the responses. The first five
+// are defined by the specification, the others are specific to this server.
+const (
+	// ParseError means the request is not valid JSON
+	ParseError = -32700
+	// InvalidRequest means the request is not a valid request object
+	InvalidRequest = -32600
+	// MethodNotFound means the method does not exist
+	MethodNotFound = -32601
+	// InvalidParams means the params are missing or have a wrong type
+	InvalidParams = -32602
+	// InternalError means the server failed to process a valid request
+	InternalError = -32603
+
+	// NotFound means the block, transaction or subscription does not exist
+	NotFound = -32000
+	// Rejected means the blockchain refused the request, ie. the consensus
+	// did not allow us to seal a block
+	Rejected = -32001
+	// SubscriptionsNotSupported means subscriptions were requested over HTTP
+	SubscriptionsNotSupported = -32002
+)
+
+// maxBodySize is the maximum size of an HTTP request or a WebSocket message
+const maxBodySize = 1 << 20
+
+// Request is a JSON-RPC request. A request without ID is a notification,
+// which gets no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response. It has either a result or an error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification is sent by the server to a WebSocket client for each event of
+// a subscription, with the "subscription" method.
+type Notification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  SubscriptionResult `json:"params"`
+}
+
+// SubscriptionResult holds the subscription ID and the event data
+type SubscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// Error is a JSON-RPC error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// NewServer returns a new JSON-RPC server. Mined blocks send their fee to me.
+// The WebSockets are only opened from the pages of the node itself, or of the
+// allowed origins, ie. "http://localhost:3000".
+func NewServer(blockchain *bc.Blockchain, me string, allowedOrigins []string) *Server {
+	return &Server{
+		blockchain:     blockchain,
+		me:             me,
+		allowedOrigins: allowedOrigins,
+	}
+}
+
+// Server serves the JSON-RPC API. POST requests are handled as HTTP calls, GET
+// requests are upgraded to WebSocket.
+//
+// - implements http.Handler
+type Server struct {
+	blockchain     *bc.Blockchain
+	me             string
+	allowedOrigins []string
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.serveHTTP(w, r)
+	case http.MethodGet:
+		s.serveWebSocket(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "use POST, or GET to open a WebSocket",
+			http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	var raw json.RawMessage
+	err := json.NewDecoder(body).Decode(&raw)
+
+	var resp interface{}
+	if err != nil {
+		resp = Response{
+			JSONRPC: Version,
+			ID:      json.RawMessage("null"),
+			Error:   newError(ParseError, err.Error()),
+		}
+	} else {
+		resp = s.handleMessage(r.Context(), raw, nil)
+	}
+
+	// only notifications: nothing to answer
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respJSON)
+}
+
+// handleMessage handles a single request or a batch. It returns nil if there
+// is nothing to answer. conn is nil over HTTP.
+func (s *Server) handleMessage(ctx context.Context, raw json.RawMessage,
+	conn *wsConn) interface{} {
+
+	trimmed := strings.TrimSpace(string(raw))
+
+	if !strings.HasPrefix(trimmed, "[") {
+		resp := s.handleRequest(ctx, raw, conn)
+		if resp == nil {
+			return nil
+		}
+		return resp
+	}
+
+	var batch []json.RawMessage
+	err := json.Unmarshal(raw, &batch)
+	if err != nil {
+		return Response{
+			JSONRPC: Version,
+			ID:      json.RawMessage("null"),
+			Error:   newError(ParseError, err.Error()),
+		}
+	}
+
+	if len(batch) == 0 {
+		return Response{
+			JSONRPC: Version,
+			ID:      json.RawMessage("null"),
+			Error:   newError(InvalidRequest, "empty batch"),
+		}
+	}
+
+	resps := make([]*Response, 0, len(batch))
+	for _, req := range batch {
+		resp := s.handleRequest(ctx, req, conn)
+		if resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		return nil
+	}
+
+	return resps
+}
+
+// handleRequest handles a single request. It returns nil for notifications.
+func (s *Server) handleRequest(ctx context.Context, raw json.RawMessage,
+	conn *wsConn) *Response {
+
+	var req Request
+	err := json.Unmarshal(raw, &req)
+	if err != nil || req.JSONRPC != Version || req.Method == "" {
+		return &Response{
+			JSONRPC: Version,
+			ID:      json.RawMessage("null"),
+			Error:   newError(InvalidRequest, "not a JSON-RPC 2.0 request"),
+		}
+	}
+
+	result, rpcErr := s.call(ctx, req.Method, req.Params, conn)
+
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	resp := &Response{
+		JSONRPC: Version,
+		ID:      req.ID,
+	}
+
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+	}
+
+	return resp
+}
+
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage,
+	conn *wsConn) (interface{}, *Error) {
+
+	switch method {
+	case "getBlockCount":
+		return len(s.blockchain.Snapshot().Chain), nil
+	case "getBlock":
+		return s.getBlock(params)
+	case "getTransaction":
+		return s.getTransaction(params)
+	case "getBalance":
+		return s.getBalance(params)
+	case "sendTransaction":
+		return s.sendTransaction(params)
+	case "mine":
+		return s.mine(ctx)
+	case "subscribe", "unsubscribe":
+		if conn == nil {
+			return nil, newError(SubscriptionsNotSupported,
+				"subscriptions are only available over WebSocket")
+		}
+
+		if method == "subscribe" {
+			return conn.subscribe(params)
+		}
+		return conn.unsubscribe(params)
+	default:
+		return nil, newError(MethodNotFound, "method not found: "+method)
+	}
+}
+
+// decodeParams decodes params given by name into dst
+func decodeParams(params json.RawMessage, dst interface{}) *Error {
+	if len(params) == 0 {
+		return newError(InvalidParams, "missing params")
+	}
+
+	err := json.Unmarshal(params, dst)
+	if err != nil {
+		return newError(InvalidParams, "params must be an object: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package rpc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	bc "dummy-blockchain/blockchain"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// websocketGUID is appended to the client key to compute the accept key, as
+// defined by RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// topics that can be subscribed to
+const (
+	// TopicNewHeads notifies the new head block each time the chain grows or
+	// is replaced.
+	TopicNewHeads = "newHeads"
+	// TopicNewTransactions notifies the transactions added to the pending
+	// ones.
+	TopicNewTransactions = "newTransactions"
+)
+
+// SubscribeParams are the params of subscribe
+type SubscribeParams struct {
+	Topic string
+}
+
+// UnsubscribeParams are the params of unsubscribe
+type UnsubscribeParams struct {
+	Subscription string
+}
+
+// serveWebSocket upgrades the connection and serves the requests sent as text
+// messages, until the client closes the connection.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "use POST, or GET to open a WebSocket",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the browsers send the cookies of the node with the WebSockets opened by
+	// any site, but always give its origin
+	if !s.allowedOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, "failed to hijack: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the server timeouts do not apply to long-lived connections
+	netConn.SetDeadline(time.Time{})
+
+	h := sha1.Sum([]byte(key + websocketGUID))
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(h[:]))
+
+	err = rw.Flush()
+	if err != nil {
+		netConn.Close()
+		return
+	}
+
+	conn := &wsConn{
+		conn:          netConn,
+		reader:        rw.Reader,
+		blockchain:    s.blockchain,
+		subscriptions: make(map[string]func()),
+	}
+
+	defer conn.close()
+
+	for {
+		msg, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+
+		resp := s.handleMessage(r.Context(), msg, conn)
+		if resp == nil {
+			continue
+		}
+
+		err = conn.writeJSON(resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wsConn is a server-side WebSocket connection
+type wsConn struct {
+	sync.Mutex
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	blockchain    *bc.Blockchain
+	subscriptions map[string]func()
+	nextID        int
+}
+
+// readMessage returns the payload of the next text message, answering pings
+// on the way. It returns an error when the connection is closed.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			err = c.writeFrame(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, payload)
+			return nil, io.EOF
+		case opBinary:
+			return nil, xerrors.Errorf("binary messages are not supported")
+		case opText, opContinuation:
+			msg = append(msg, payload...)
+		default:
+			return nil, xerrors.Errorf("unknown opcode %d", opcode)
+		}
+
+		if len(msg) > maxBodySize {
+			return nil, xerrors.Errorf("message too large")
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame. Client frames are always masked.
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	_, err := io.ReadFull(c.reader, header[:])
+	if err != nil {
+		return false, 0, nil, xerrors.Errorf("failed to read header: %v", err)
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.reader, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.reader, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if err != nil {
+		return false, 0, nil, xerrors.Errorf("failed to read length: %v", err)
+	}
+
+	if !masked {
+		return false, 0, nil, xerrors.Errorf("client frames must be masked")
+	}
+
+	if length > maxBodySize {
+		return false, 0, nil, xerrors.Errorf("frame too large: %d", length)
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(c.reader, mask[:])
+	if err != nil {
+		return false, 0, nil, xerrors.Errorf("failed to read mask: %v", err)
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return false, 0, nil, xerrors.Errorf("failed to read payload: %v", err)
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame. It can be called concurrently.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	header := []byte{0x80 | opcode}
+
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	_, err := c.conn.Write(append(header, payload...))
+	if err != nil {
+		return xerrors.Errorf("failed to write frame: %v", err)
+	}
+
+	return nil
+}
+
+func (c *wsConn) writeJSON(v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal: %v", err)
+	}
+
+	return c.writeFrame(opText, buf)
+}
+
+// subscribe starts forwarding the events of a topic to the client
+func (c *wsConn) subscribe(params json.RawMessage) (interface{}, *Error) {
+	var p SubscribeParams
+	rpcErr := decodeParams(params, &p)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	if p.Topic != TopicNewHeads && p.Topic != TopicNewTransactions {
+		return nil, newError(InvalidParams, "unknown topic: "+p.Topic)
+	}
+
+	events, unsubscribe := c.blockchain.Events.Subscribe()
+
+	c.Lock()
+	c.nextID++
+	id := "0x" + strconv.FormatInt(int64(c.nextID), 16)
+	c.subscriptions[id] = unsubscribe
+	c.Unlock()
+
+	go func() {
+		for event := range events {
+			result := topicResult(p.Topic, event)
+			if result == nil {
+				continue
+			}
+
+			c.writeJSON(Notification{
+				JSONRPC: Version,
+				Method:  "subscription",
+				Params: SubscriptionResult{
+					Subscription: id,
+					Result:       result,
+				},
+			})
+		}
+	}()
+
+	return id, nil
+}
+
+// unsubscribe stops a subscription
+func (c *wsConn) unsubscribe(params json.RawMessage) (interface{}, *Error) {
+	var p UnsubscribeParams
+	rpcErr := decodeParams(params, &p)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	c.Lock()
+	unsubscribe, found := c.subscriptions[p.Subscription]
+	delete(c.subscriptions, p.Subscription)
+	c.Unlock()
+
+	if !found {
+		return nil, newError(NotFound, "subscription not found")
+	}
+
+	unsubscribe()
+
+	return true, nil
+}
+
+// close stops the subscriptions and closes the connection
+func (c *wsConn) close() {
+	c.Lock()
+	subscriptions := c.subscriptions
+	c.subscriptions = make(map[string]func())
+	c.Unlock()
+
+	for _, unsubscribe := range subscriptions {
+		unsubscribe()
+	}
+
+	c.conn.Close()
+}
+
+// topicResult returns the data to notify for an event, or nil if the event
+// does not belong to the topic.
+func topicResult(topic string, event bc.Event) interface{} {
+	switch {
+	case topic == TopicNewHeads && event.Type == bc.EventBlockAdded:
+		return event.Data
+	case topic == TopicNewHeads && event.Type == bc.EventReorg:
+		return event.Data.(bc.Reorg).Head
+	case topic == TopicNewTransactions && event.Type == bc.EventTxAdded:
+		return event.Data
+	default:
+		return nil
+	}
+}
+
+// headerContains tells if a comma-separated header contains the token, case
+// insensitive.
+// allowedOrigin returns true if the request has no Origin, as sent by the
+// clients outside of a browser, or if it comes from a page of the node or of an
+// allowed origin.
+func (s *Server) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range s.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/gui/controllers"
+	"dummy-blockchain/logs"
+	"dummy-blockchain/metrics"
+	"dummy-blockchain/openapi"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// logging logs the requests, with the logger of their context, and records
+// their metrics. The route of a request is the pattern of the mux that
+// handles it.
+func logging(httpMetrics *metrics.HTTPMetrics, mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			defer func() {
+				_, route := mux.Handler(r)
+				elapsed := time.Since(start)
+
+				logs.FromContext(r.Context()).Info("request", "method", r.Method,
+					"path", r.URL.Path, "route", route, "status", recorder.status,
+					"duration", elapsed, "remote_addr", r.RemoteAddr,
+					"user_agent", r.UserAgent())
+
+				httpMetrics.Observe(route, r.Method, recorder.status, elapsed)
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// statusRecorder keeps the status code of a response. It forwards Flush and
+// Hijack, used by the SSE and WebSocket endpoints.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher
+func (s *statusRecorder) Flush() {
+	flusher, ok := s.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, xerrors.Errorf("hijack not supported")
+	}
+
+	s.status = http.StatusSwitchingProtocols
+
+	return hijacker.Hijack()
+}
+
+// validating rejects the JSON bodies that do not match the OpenAPI spec, with
+// the list of invalid fields.
+func validating(spec *openapi.Spec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schema := spec.BodySchema(r.URL.Path, r.Method)
+
+			// the versioned API answers 415 to non-JSON bodies
+			if schema == nil || (strings.HasPrefix(r.URL.Path, controllers.APIPrefix) &&
+				!controllers.IsJSON(r)) {
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+			if err != nil {
+				controllers.RenderJSONError(w, "failed to read body: "+err.Error(),
+					http.StatusBadRequest)
+				return
+			}
+
+			fields, err := spec.ValidateBody(schema, body)
+			if err != nil {
+				controllers.RenderJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if len(fields) > 0 {
+				controllers.RenderJSONFieldErrors(w, "the body does not match the "+
+					"OpenAPI spec", http.StatusBadRequest, fields)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// networking sets the chain ID and the network magic of the node on every
+// response, and rejects the requests of the nodes of another network. The
+// requests without a network magic, from a browser or a script, are accepted.
+func networking(blockchain *bc.Blockchain) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			blockchain.SetNetwork(w.Header())
+
+			if r.Header.Get(bc.MagicHeader) != "" {
+				err := blockchain.CheckNetwork(r.Header)
+				if err != nil {
+					controllers.RenderJSONError(w, err.Error(), http.StatusConflict)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tracing gives an ID to each request, or keeps the one set by the client,
+// and puts it in the context along with the logger.
+func tracing(logger *slog.Logger, nextRequestID func() string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(logs.RequestIDHeader)
+			if requestID == "" {
+				requestID = nextRequestID()
+			}
+			ctx := logs.WithLogger(r.Context(), logger)
+			ctx = logs.WithRequestID(ctx, requestID)
+			w.Header().Set(logs.RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
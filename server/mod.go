@@ -0,0 +1,279 @@
+// Package server wires a blockchain to its http interface: the GUI, the REST
+// and JSON-RPC APIs, the events and the metrics. It is used by the node
+// binary, and by simnet to run several nodes in one process.
+package server
+
+import (
+	"dummy-blockchain/attack"
+	"dummy-blockchain/blockchain"
+	"dummy-blockchain/faults"
+	"dummy-blockchain/gui"
+	"dummy-blockchain/gui/controllers"
+	"dummy-blockchain/logs"
+	"dummy-blockchain/metrics"
+	"dummy-blockchain/openapi"
+	"dummy-blockchain/rpc"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Config is the configuration of a node
+type Config struct {
+	// Address is the address of the node, which creates the transaction fees
+	Address string
+	// Owner is the address receiving the transaction fees
+	Owner string
+	// Genesis is the configuration of the network. Defaults to
+	// blockchain.DefaultGenesis.
+	Genesis   *blockchain.Genesis
+	Consensus blockchain.Consensus
+	Logger    *slog.Logger
+
+	// Transport is used to contact the other nodes. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Listen is the host and port at which the other nodes reach the node,
+	// sent in the handshake so that they add it back
+	Listen *blockchain.Node
+
+	// Attacker enables the double-spend demonstration on the node
+	Attacker bool
+
+	// AdminToken is required by the admin actions, ie. setting the faults or
+	// forcing an import. They are disabled if it is empty.
+	AdminToken string
+
+	// AllowedOrigins are the origins, besides the node itself, whose pages
+	// can open a WebSocket on /rpc, ie. "http://localhost:3000"
+	AllowedOrigins []string
+
+	// Clock and Entropy default to the system clock and crypto/rand. Give a
+	// simulated clock and a seeded entropy to reproduce a chain.
+	Clock   blockchain.Clock
+	Entropy io.Reader
+}
+
+// Server is a node: a blockchain and the handler serving it
+type Server struct {
+	Blockchain *blockchain.Blockchain
+	Registry   *metrics.Registry
+	Faults     *faults.Injector
+	// Attacker is nil if the node is not an attacker
+	Attacker *attack.Attacker
+	Handler  http.Handler
+	// Endpoints are the REST endpoints of the handler, checked against the
+	// OpenAPI spec
+	Endpoints []openapi.Endpoint
+}
+
+// New creates the blockchain of a node and its handler. It fails if the
+// REST endpoints do not match the OpenAPI spec.
+func New(config Config) (*Server, error) {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	blockchain := blockchain.NewBlockchain(config.Address, config.Genesis,
+		config.Consensus, config.Clock, config.Entropy)
+	blockchain.Listen = config.Listen
+	ownerAddr := config.Owner
+
+	var seed int64
+	err := binary.Read(blockchain.Entropy, binary.BigEndian, &seed)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read the seed of the faults: %v", err)
+	}
+
+	// the faults are injected below the metrics, so that they show the
+	// injected latency and failures
+	injector := faults.NewInjector(transport, seed)
+
+	registry := metrics.NewRegistry()
+	blockchain.Client = &http.Client{
+		Transport: logs.NewTransport(
+			metrics.RegisterNode(registry, blockchain, injector)),
+	}
+
+	var attacker *attack.Attacker
+	if config.Attacker {
+		attacker = attack.NewAttacker(blockchain, ownerAddr)
+	}
+
+	renderer, err := controllers.NewRenderer(gui.Views)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse views: %v", err)
+	}
+
+	mux := http.NewServeMux()
+
+	// endpoints are the REST endpoints, which must match the OpenAPI spec
+	endpoints := make([]openapi.Endpoint, 0)
+	rest := func(path string, handler http.HandlerFunc, methods ...string) {
+		mux.HandleFunc(path, handler)
+		endpoints = append(endpoints, openapi.Endpoint{Path: path, Methods: methods})
+	}
+
+	assets, err := fs.Sub(gui.Assets, "assets")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load assets: %v", err)
+	}
+
+	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(assets))))
+	mux.Handle("/favicon.ico", faviconHandler(assets))
+
+	// HTML endpoint
+	mux.HandleFunc("/", controllers.HomeHandler(renderer, blockchain, ownerAddr))
+	// REST endpoint
+	rest("/get_chain", controllers.GetChainHandler(blockchain), http.MethodGet)
+
+	// JSON-RPC endpoint, over HTTP POST and WebSocket
+	mux.Handle("/rpc", rpc.NewServer(blockchain, ownerAddr, config.AllowedOrigins))
+
+	// SSE endpoint
+	mux.HandleFunc("/events", controllers.EventsHandler(blockchain))
+
+	// HTML endpoints
+	mux.HandleFunc("/block/", controllers.BlockHandler(renderer, blockchain))
+	mux.HandleFunc("/tx/", controllers.TxHandler(renderer, blockchain))
+	mux.HandleFunc("/address/", controllers.AddressHandler(renderer, blockchain))
+	mux.HandleFunc("/search", controllers.SearchHandler(blockchain))
+
+	// HTML endpoint
+	mux.HandleFunc("/transaction", controllers.TransactionHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/add_transaction", controllers.AddTransactionHandler(blockchain), http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/mine", controllers.MineHandler(renderer, blockchain, ownerAddr))
+	// REST endpoint
+	rest("/mine_block", controllers.MineRESTHandler(blockchain, ownerAddr), http.MethodGet)
+
+	// HTML endpoint
+	mux.HandleFunc("/replace", controllers.ReplaceHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/replace_chain", controllers.ReplaceChainHandler(blockchain), http.MethodGet)
+
+	// HTML endpoint
+	mux.HandleFunc("/node", controllers.NodeHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/add_node", controllers.ConnectNodesHandler(blockchain), http.MethodPost)
+	// former name of /add_node
+	rest("/connect_node", controllers.ConnectNodesHandler(blockchain), http.MethodPost)
+	// handshake of the other nodes, before they are added
+	rest("/handshake", controllers.HandshakeHandler(blockchain), http.MethodGet,
+		http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/script", controllers.ScriptHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/trace_script", controllers.TraceScriptHandler(blockchain), http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/multisig", controllers.MultisigHandler(renderer, blockchain))
+	// REST endpoints
+	rest("/add_partial_transaction",
+		controllers.AddPartialTransactionHandler(blockchain), http.MethodPost)
+	rest("/finalize_partial_transaction",
+		controllers.FinalizePartialTransactionHandler(blockchain), http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/poa", controllers.PoAHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/vote", controllers.VoteHandler(blockchain), http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/pos", controllers.PoSHandler(renderer, blockchain))
+	// REST endpoint
+	rest("/slash", controllers.SlashHandler(blockchain), http.MethodPost)
+
+	// REST endpoint
+	rest("/is_valid", controllers.IsValidHandler(blockchain), http.MethodGet)
+
+	// REST endpoints
+	rest("/export", controllers.ExportHandler(blockchain), http.MethodGet)
+	rest("/import", controllers.ImportHandler(blockchain, config.AdminToken),
+		http.MethodPost)
+
+	// HTML endpoint
+	mux.HandleFunc("/faults", controllers.FaultsHandler(renderer, blockchain, injector,
+		config.AdminToken))
+	// REST endpoint
+	rest("/admin/faults", controllers.FaultsRESTHandler(injector, config.AdminToken),
+		http.MethodGet, http.MethodPut)
+
+	// HTML endpoint
+	mux.HandleFunc("/attack", controllers.AttackHandler(renderer, blockchain, attacker))
+
+	// versioned REST API
+	mux.Handle(controllers.APIPrefix+"/", controllers.APIHandler(blockchain, ownerAddr))
+
+	for path, methods := range controllers.APIRoutes(blockchain, ownerAddr) {
+		endpoint := openapi.Endpoint{Path: path}
+		for method := range methods {
+			endpoint.Methods = append(endpoint.Methods, method)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	// Prometheus endpoint
+	mux.HandleFunc("/metrics", registry.Handler())
+
+	// OpenAPI specification and its HTML page
+	mux.HandleFunc("/openapi.json", controllers.OpenAPIHandler())
+	mux.HandleFunc("/docs", controllers.DocsHandler(renderer))
+
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load the OpenAPI spec: %v", err)
+	}
+
+	err = spec.CheckEndpoints(endpoints)
+	if err != nil {
+		return nil, xerrors.Errorf("the OpenAPI spec is out of date: %v", err)
+	}
+
+	nextRequestID := func() string {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	httpMetrics := metrics.NewHTTPMetrics(registry)
+
+	s := &Server{
+		Blockchain: blockchain,
+		Registry:   registry,
+		Faults:     injector,
+		Attacker:   attacker,
+		Handler: tracing(logger, nextRequestID)(logging(httpMetrics, mux)(
+			networking(blockchain)(validating(spec)(mux)))),
+		Endpoints: endpoints,
+	}
+
+	return s, nil
+}
+
+func faviconHandler(assets fs.FS) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		favicon, err := fs.ReadFile(assets, "images/favicon.ico")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/x-icon")
+		w.Write(favicon)
+	}
+}
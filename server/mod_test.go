@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"dummy-blockchain/blockchain"
+	"dummy-blockchain/openapi"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := New(Config{
+		Address: "node",
+		Owner:   "owner",
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	return s
+}
+
+func TestEndpointsMatchSpec(t *testing.T) {
+	s := newTestServer(t)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+
+	if len(s.Endpoints) == 0 {
+		t.Fatal("no endpoint registered")
+	}
+
+	err = spec.CheckEndpoints(s.Endpoints)
+	if err != nil {
+		t.Fatalf("spec and handlers drifted apart: %v", err)
+	}
+}
+
+func TestEndpointsDrift(t *testing.T) {
+	s := newTestServer(t)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+
+	undocumented := append([]openapi.Endpoint{}, s.Endpoints...)
+	undocumented = append(undocumented, openapi.Endpoint{
+		Path:    "/undocumented",
+		Methods: []string{"GET"},
+	})
+
+	err = spec.CheckEndpoints(undocumented)
+	if err == nil || !strings.Contains(err.Error(), "GET /undocumented is not in the spec") {
+		t.Fatalf("undocumented endpoint not reported: %v", err)
+	}
+
+	missing := s.Endpoints[1:]
+
+	err = spec.CheckEndpoints(missing)
+	if err == nil || !strings.Contains(err.Error(), s.Endpoints[0].Path+" is not registered") {
+		t.Fatalf("unregistered endpoint not reported: %v", err)
+	}
+}
+
+func TestSetFaultsRequiresAdminToken(t *testing.T) {
+	tokens := map[string]int{
+		"":       http.StatusForbidden,
+		"wrong":  http.StatusForbidden,
+		"secret": http.StatusOK,
+	}
+
+	for token, status := range tokens {
+		s, err := New(Config{
+			Address:    "node",
+			Owner:      "owner",
+			Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+			AdminToken: "secret",
+		})
+		if err != nil {
+			t.Fatalf("failed to create server: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/faults",
+			strings.NewReader(`{"LatencyMs": 10}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		s.Handler.ServeHTTP(rec, req)
+
+		if rec.Code != status {
+			t.Errorf("token '%s': status %d instead of %d", token, rec.Code, status)
+		}
+
+		latency := s.Faults.Config().LatencyMs
+		if (status == http.StatusOK) != (latency == 10) {
+			t.Errorf("token '%s': latency %d after status %d", token, latency, rec.Code)
+		}
+	}
+}
+
+func TestImportKeepsPreferredChain(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Difficulty = "00"
+
+	s, err := New(Config{
+		Address:    "node",
+		Owner:      "owner",
+		Genesis:    genesis,
+		Consensus:  blockchain.NewProofOfWork(genesis.Difficulty),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		AdminToken: "secret",
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	var exported bytes.Buffer
+	err = blockchain.ExportChain(&exported, s.Blockchain.Chain, blockchain.Binary)
+	if err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+
+	_, err = s.Blockchain.MineBlock(context.Background(), "owner")
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+
+	imports := []struct {
+		query  string
+		token  string
+		status int
+		height int
+	}{
+		{"", "", http.StatusBadRequest, 2},
+		{"?force=true", "", http.StatusForbidden, 2},
+		{"?force=true", "wrong", http.StatusForbidden, 2},
+		{"?force=true", "secret", http.StatusOK, 1},
+	}
+
+	for _, imp := range imports {
+		req := httptest.NewRequest(http.MethodPost, "/import"+imp.query,
+			bytes.NewReader(exported.Bytes()))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if imp.token != "" {
+			req.Header.Set("Authorization", "Bearer "+imp.token)
+		}
+
+		rec := httptest.NewRecorder()
+		s.Handler.ServeHTTP(rec, req)
+
+		if rec.Code != imp.status {
+			t.Errorf("import%s with token '%s': status %d instead of %d: %s",
+				imp.query, imp.token, rec.Code, imp.status, rec.Body.String())
+		}
+
+		if len(s.Blockchain.Chain) != imp.height {
+			t.Errorf("import%s with token '%s': height %d instead of %d",
+				imp.query, imp.token, len(s.Blockchain.Chain), imp.height)
+		}
+	}
+}
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	s, err := New(Config{
+		Address:        "node",
+		Owner:          "owner",
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		AllowedOrigins: []string{"http://localhost:3000"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	ts := httptest.NewServer(s.Handler)
+	defer ts.Close()
+
+	origins := map[string]int{
+		"":                      http.StatusSwitchingProtocols,
+		ts.URL:                  http.StatusSwitchingProtocols,
+		"http://localhost:3000": http.StatusSwitchingProtocols,
+		"http://evil.example":   http.StatusForbidden,
+		"http://localhost:3001": http.StatusForbidden,
+		"null":                  http.StatusForbidden,
+	}
+
+	for origin, status := range origins {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/rpc", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to open WebSocket: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != status {
+			t.Errorf("origin '%s': status %d instead of %d", origin, resp.StatusCode,
+				status)
+		}
+	}
+}
+
+// startTestNode serves a node with the given address, listening on a local port
+func startTestNode(t *testing.T, address string) (*Server, *blockchain.Node) {
+	t.Helper()
+
+	s, err := New(Config{
+		Address: address,
+		Owner:   "owner",
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	ts := httptest.NewServer(s.Handler)
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+
+	s.Blockchain.Listen = blockchain.NewNode(u.Hostname(), port)
+
+	return s, s.Blockchain.Listen
+}
+
+func TestHandshakeSkipsUnverifiedListen(t *testing.T) {
+	a, aNode := startTestNode(t, "a")
+	b, bNode := startTestNode(t, "b")
+	_, cNode := startTestNode(t, "c")
+
+	// a gives the listen address of c: b answers, but does not add c
+	a.Blockchain.Listen = cNode
+
+	err := a.Blockchain.Connect(context.Background(), bNode)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	if len(a.Blockchain.Nodes) != 1 {
+		t.Fatalf("a knows %d nodes instead of 1", len(a.Blockchain.Nodes))
+	}
+
+	if len(b.Blockchain.Nodes) != 0 {
+		t.Fatalf("b added %s", b.Blockchain.Nodes[0])
+	}
+
+	rejected := b.Blockchain.RejectedNodes
+	if len(rejected) != 1 || rejected[0].Node.String() != cNode.String() ||
+		!strings.Contains(rejected[0].Reason, "not verified") {
+
+		t.Fatalf("b rejected %v instead of %s", rejected, cNode)
+	}
+
+	a.Blockchain.Listen = aNode
+
+	err = a.Blockchain.Connect(context.Background(), bNode)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	if len(b.Blockchain.Nodes) != 1 || b.Blockchain.Nodes[0].String() != aNode.String() {
+		t.Fatalf("b knows %v instead of %s", b.Blockchain.Nodes, aNode)
+	}
+}
@@ -0,0 +1,342 @@
+// Package simnet runs a network of nodes in a single process, each one served
+// by an httptest server. Scenarios are scripted as a list of steps, ie. mine,
+// send a transaction, partition the network, heal it, and check that the
+// nodes converge to the same chain.
+//
+//	network, err := simnet.New(simnet.Config{Nodes: 3})
+//	...
+//	defer network.Close()
+//
+//	err = network.Run(
+//		simnet.Partition([]int{0}, []int{1, 2}),
+//		simnet.Mine(0), simnet.Mine(1), simnet.Mine(1),
+//		simnet.Heal(),
+//		simnet.Sync(),
+//		simnet.AssertConverged(),
+//	)
+package simnet
+
+import (
+	"context"
+	bc "dummy-blockchain/blockchain"
+	"dummy-blockchain/logs"
+	"dummy-blockchain/server"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Config is the configuration of a simulated network
+type Config struct {
+	// Nodes is the number of nodes
+	Nodes int
+	// Genesis is the configuration of the network. Defaults to the default
+	// genesis with an easy difficulty.
+	Genesis *bc.Genesis
+	// Consensus returns the consensus engine of the i-th node. Defaults to the
+	// consensus of the genesis.
+	Consensus func(i int) bc.Consensus
+	// Logger is the logger of the nodes. Defaults to no logs.
+	Logger *slog.Logger
+	// Clock returns the clock of the i-th node. Defaults to the system clock.
+	// Give simulated clocks to reproduce the same chains on each run.
+	Clock func(i int) bc.Clock
+}
+
+// New starts the nodes, named node0, node1, and so on, and connects each one
+// to all the others with a handshake, as the nodes of a real network.
+func New(config Config) (*Network, error) {
+	if config.Nodes < 1 {
+		return nil, xerrors.Errorf("need at least one node, got %d", config.Nodes)
+	}
+
+	if config.Genesis == nil {
+		config.Genesis = bc.DefaultGenesis()
+		config.Genesis.Difficulty = "00"
+	}
+
+	err := config.Genesis.Validate()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid genesis: %v", err)
+	}
+
+	if config.Consensus == nil {
+		config.Consensus = func(int) bc.Consensus {
+			// the genesis is valid, so its consensus is
+			consensus, _ := config.Genesis.NewConsensus("")
+			return consensus
+		}
+	}
+
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if config.Clock == nil {
+		config.Clock = func(int) bc.Clock {
+			return bc.SystemClock{}
+		}
+	}
+
+	network := &Network{
+		Nodes:  make([]*Node, 0, config.Nodes),
+		byHost: make(map[string]*Node),
+	}
+
+	for i := 0; i < config.Nodes; i++ {
+		name := "node" + strconv.Itoa(i)
+
+		s, err := server.New(server.Config{
+			Address:   name,
+			Owner:     name,
+			Genesis:   config.Genesis,
+			Consensus: config.Consensus(i),
+			Logger:    config.Logger.With("node", name),
+			Transport: &link{network: network, from: name, next: http.DefaultTransport},
+			Clock:     config.Clock(i),
+		})
+		if err != nil {
+			network.Close()
+			return nil, xerrors.Errorf("failed to create %s: %v", name, err)
+		}
+
+		node := &Node{
+			Name:   name,
+			Server: s,
+			http:   httptest.NewServer(s.Handler),
+			logger: config.Logger.With("node", name),
+		}
+
+		u, err := url.Parse(node.http.URL)
+		if err != nil {
+			network.Close()
+			return nil, xerrors.Errorf("failed to parse url: %v", err)
+		}
+
+		node.host = u.Host
+
+		network.Nodes = append(network.Nodes, node)
+		network.byHost[u.Host] = node
+
+		host, portStr, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			network.Close()
+			return nil, xerrors.Errorf("failed to split host: %v", err)
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			network.Close()
+			return nil, xerrors.Errorf("failed to parse port: %v", err)
+		}
+
+		// the nodes are only known once the httptest server is started
+		s.Blockchain.Listen = bc.NewNode(host, port)
+	}
+
+	// each node connects to the next ones, which add it back
+	for i, node := range network.Nodes {
+		for _, peer := range network.Nodes[i+1:] {
+			err := node.Blockchain.Connect(node.Context(), peer.Blockchain.Listen)
+			if err != nil {
+				network.Close()
+				return nil, xerrors.Errorf("failed to connect %s to %s: %v",
+					node.Name, peer.Name, err)
+			}
+		}
+	}
+
+	return network, nil
+}
+
+// Network is a set of nodes running in the same process
+type Network struct {
+	sync.Mutex
+
+	Nodes []*Node
+
+	byHost map[string]*Node
+	// groups gives the group of each node name while the network is
+	// partitioned, it is nil otherwise.
+	groups map[string]int
+}
+
+// Node is a node of the network
+type Node struct {
+	Name string
+	*server.Server
+
+	http   *httptest.Server
+	host   string
+	logger *slog.Logger
+}
+
+// URL returns the base URL of the node, ie. to use its REST API
+func (n *Node) URL() string {
+	return n.http.URL
+}
+
+// Context returns a context carrying the logger of the node
+func (n *Node) Context() context.Context {
+	return logs.WithLogger(context.Background(), n.logger)
+}
+
+// Close stops all the nodes
+func (n *Network) Close() {
+	for _, node := range n.Nodes {
+		node.http.Close()
+	}
+}
+
+// Node returns the i-th node, or an error if there is none
+func (n *Network) Node(i int) (*Node, error) {
+	if i < 0 || i >= len(n.Nodes) {
+		return nil, xerrors.Errorf("no node %d in a network of %d", i, len(n.Nodes))
+	}
+
+	return n.Nodes[i], nil
+}
+
+// Partition splits the network: the nodes only reach the nodes of their
+// group. A node that is in no group is isolated.
+func (n *Network) Partition(groups ...[]int) error {
+	partition := make(map[string]int)
+
+	for g, group := range groups {
+		for _, i := range group {
+			node, err := n.Node(i)
+			if err != nil {
+				return err
+			}
+
+			partition[node.Name] = g
+		}
+	}
+
+	n.Lock()
+	n.groups = partition
+	n.Unlock()
+
+	return nil
+}
+
+// Heal removes the partition
+func (n *Network) Heal() {
+	n.Lock()
+	n.groups = nil
+	n.Unlock()
+}
+
+// Reachable tells if a node can send requests to another node
+func (n *Network) Reachable(from, to string) bool {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.groups == nil || from == to {
+		return true
+	}
+
+	fromGroup, found := n.groups[from]
+	if !found {
+		return false
+	}
+
+	toGroup, found := n.groups[to]
+
+	return found && fromGroup == toGroup
+}
+
+// Sync makes every node look for a better chain on the nodes it reaches, until
+// no chain changes anymore.
+func (n *Network) Sync() error {
+	// a chain crosses at least one hop per round
+	for round := 0; round < len(n.Nodes); round++ {
+		changed := false
+
+		for _, node := range n.Nodes {
+			replaced, err := node.Blockchain.ReplaceChain(node.Context())
+			if err != nil && !n.isolated(node) {
+				return xerrors.Errorf("failed to sync %s: %v", node.Name, err)
+			}
+
+			changed = changed || replaced
+		}
+
+		if !changed {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Converged returns an error if the nodes do not all have the same chain
+func (n *Network) Converged() error {
+	heads := make(map[bc.Hash][]string)
+
+	for _, node := range n.Nodes {
+		head, err := node.Blockchain.GetPreviousBlock().Hash()
+		if err != nil {
+			return xerrors.Errorf("failed to get hash: %v", err)
+		}
+
+		heads[head] = append(heads[head], fmt.Sprintf("%s at height %d",
+			node.Name, len(node.Blockchain.Chain)-1))
+	}
+
+	if len(heads) == 1 {
+		return nil
+	}
+
+	forks := make([]string, 0, len(heads))
+	for head, nodes := range heads {
+		forks = append(forks, fmt.Sprintf("%s (%s)", head.String()[:8],
+			strings.Join(nodes, ", ")))
+	}
+	sort.Strings(forks)
+
+	return xerrors.Errorf("%d different heads: %s", len(heads),
+		strings.Join(forks, "; "))
+}
+
+// isolated tells if a node reaches no other node
+func (n *Network) isolated(node *Node) bool {
+	for _, peer := range n.Nodes {
+		if peer != node && n.Reachable(node.Name, peer.Name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// link is the transport between a node and the others. It fails the requests
+// to the nodes that are not reachable.
+//
+// - implements http.RoundTripper
+type link struct {
+	network *Network
+	from    string
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (l *link) RoundTrip(req *http.Request) (*http.Response, error) {
+	to, found := l.network.byHost[req.URL.Host]
+	if found && !l.network.Reachable(l.from, to.Name) {
+		return nil, xerrors.Errorf("%s cannot reach %s: network partitioned",
+			l.from, to.Name)
+	}
+
+	return l.next.RoundTrip(req)
+}
@@ -0,0 +1,64 @@
+package simnet
+
+import (
+	"testing"
+)
+
+func newTestNetwork(t *testing.T, nodes int) *Network {
+	network, err := New(Config{Nodes: nodes})
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+
+	t.Cleanup(network.Close)
+
+	return network
+}
+
+func TestNodesConnectWithHandshake(t *testing.T) {
+	network := newTestNetwork(t, 3)
+
+	for _, node := range network.Nodes {
+		if len(node.Blockchain.Nodes) != 2 {
+			t.Fatalf("%s knows %d nodes instead of 2", node.Name,
+				len(node.Blockchain.Nodes))
+		}
+
+		for _, peer := range node.Blockchain.Nodes {
+			if peer.Version == 0 || len(peer.Features) == 0 {
+				t.Errorf("%s added %s without a handshake", node.Name, peer)
+			}
+		}
+
+		if len(node.Blockchain.RejectedNodes) != 0 {
+			t.Errorf("%s rejected %s: %s", node.Name,
+				node.Blockchain.RejectedNodes[0].Node,
+				node.Blockchain.RejectedNodes[0].Reason)
+		}
+	}
+}
+
+func TestPartitionDivergesAndHealConverges(t *testing.T) {
+	network := newTestNetwork(t, 3)
+
+	err := network.Run(
+		Partition([]int{0}, []int{1, 2}),
+		Mine(0),
+		Mine(1),
+		Mine(1),
+		Sync(),
+		// each side of the partition grew its own chain
+		AssertDiverged(),
+		AssertHeight(0, 1),
+		AssertHeight(1, 2),
+		AssertHeight(2, 2),
+		Heal(),
+		Sync(),
+		// the longest chain won
+		AssertConverged(),
+		AssertHeight(0, 2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
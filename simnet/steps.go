@@ -0,0 +1,139 @@
+package simnet
+
+import (
+	bc "dummy-blockchain/blockchain"
+
+	"golang.org/x/xerrors"
+)
+
+// Step is an action of a scenario
+type Step struct {
+	Name string
+	Do   func(*Network) error
+}
+
+// Run runs the steps in order, and stops at the first one that fails
+func (n *Network) Run(steps ...Step) error {
+	for i, step := range steps {
+		err := step.Do(n)
+		if err != nil {
+			return xerrors.Errorf("step %d (%s) failed: %v", i+1, step.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Mine makes a node mine a block with its pending transactions
+func Mine(node int) Step {
+	return onNode("mine", node, func(n *Node) error {
+		_, err := n.Blockchain.MineBlock(n.Context(), n.Name)
+		return err
+	})
+}
+
+// SendTx adds a transaction to the pending transactions of a node
+func SendTx(node int, tx *bc.Transaction) Step {
+	return onNode("send tx", node, func(n *Node) error {
+		_, err := n.Blockchain.AddTransaction(tx)
+		return err
+	})
+}
+
+// Replace makes a node look for a better chain on the nodes it reaches
+func Replace(node int) Step {
+	return onNode("replace chain", node, func(n *Node) error {
+		_, err := n.Blockchain.ReplaceChain(n.Context())
+		return err
+	})
+}
+
+// Sync makes all the nodes replace their chains until nothing changes
+func Sync() Step {
+	return Step{
+		Name: "sync",
+		Do: func(n *Network) error {
+			return n.Sync()
+		},
+	}
+}
+
+// Partition splits the network into groups of nodes
+func Partition(groups ...[]int) Step {
+	return Step{
+		Name: "partition",
+		Do: func(n *Network) error {
+			return n.Partition(groups...)
+		},
+	}
+}
+
+// Heal removes the partition
+func Heal() Step {
+	return Step{
+		Name: "heal",
+		Do: func(n *Network) error {
+			n.Heal()
+			return nil
+		},
+	}
+}
+
+// AssertConverged fails if the nodes do not all have the same chain
+func AssertConverged() Step {
+	return Step{
+		Name: "assert converged",
+		Do: func(n *Network) error {
+			return n.Converged()
+		},
+	}
+}
+
+// AssertDiverged fails if the nodes all have the same chain
+func AssertDiverged() Step {
+	return Step{
+		Name: "assert diverged",
+		Do: func(n *Network) error {
+			if n.Converged() == nil {
+				return xerrors.Errorf("all the nodes have the same chain")
+			}
+			return nil
+		},
+	}
+}
+
+// AssertHeight fails if the last block of a node is not at the given height
+func AssertHeight(node, height int) Step {
+	return onNode("assert height", node, func(n *Node) error {
+		got := len(n.Blockchain.Chain) - 1
+		if got != height {
+			return xerrors.Errorf("%s is at height %d, expected %d", n.Name, got, height)
+		}
+		return nil
+	})
+}
+
+// AssertBalance fails if an address does not have the given balance on a node
+func AssertBalance(node int, address string, balance int) Step {
+	return onNode("assert balance", node, func(n *Node) error {
+		got := n.Blockchain.GetBalance(address)
+		if got != balance {
+			return xerrors.Errorf("%s has %d on %s, expected %d", address, got,
+				n.Name, balance)
+		}
+		return nil
+	})
+}
+
+func onNode(name string, node int, do func(*Node) error) Step {
+	return Step{
+		Name: name,
+		Do: func(n *Network) error {
+			target, err := n.Node(node)
+			if err != nil {
+				return err
+			}
+			return do(target)
+		},
+	}
+}